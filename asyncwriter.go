@@ -0,0 +1,211 @@
+package xlg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Overflow is a policy of AsyncWriter when its queue is full.
+type Overflow int
+
+const (
+	// DropNewest drops the record being written, so writing never blocks.
+	DropNewest Overflow = iota
+	// DropOldest drops the oldest queued record to make room for the new one.
+	DropOldest
+	// Block waits until there is room in the queue.
+	Block
+)
+
+var ErrClosed = errors.New("xlg: writer is closed")
+
+// AsyncWriter queues written records in memory and writes them to the underlying writer in background workers,
+// so a slow writer, e.g. HttpWriter retrying during a collector outage, does not freeze the code which logs.
+//
+// The queue is bounded, records which do not fit are handled according to the Overflow policy and counted by Dropped.
+// Flush and Close should be called on shutdown to drain the queue.
+//
+//	w := xlg.NewAsyncWriter(xlg.HttpWriter{URL: url}, 10000, 1, xlg.DropOldest)
+//	xlg.SetOutput(w)
+//	defer w.Close(shutdownCtx)
+type AsyncWriter struct {
+	w        io.Writer
+	size     int
+	overflow Overflow
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    [][]byte
+	// pending counts records which are queued or being written by workers.
+	pending int
+	// idle is closed when pending drops to zero, it is created on demand by Flush.
+	idle   chan struct{}
+	closed bool
+	done   chan struct{}
+
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// NewAsyncWriter starts workers writing to w from a queue of size records.
+// Records may be reordered when there are several workers.
+func NewAsyncWriter(w io.Writer, size, workers int, overflow Overflow) *AsyncWriter {
+	size = max(size, 1)
+	workers = max(workers, 1)
+	a := &AsyncWriter{
+		w:        w,
+		size:     size,
+		overflow: overflow,
+		queue:    make([][]byte, 0, size),
+		done:     make(chan struct{}),
+	}
+	a.notEmpty = sync.NewCond(&a.mu)
+	a.notFull = sync.NewCond(&a.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(a.done)
+	}()
+	return a
+}
+
+// Write queues a copy of p, as the caller may reuse it. It does not block unless the overflow policy is Block.
+func (a *AsyncWriter) Write(p []byte) (n int, err error) {
+	b := append([]byte(nil), p...)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for {
+		if a.closed {
+			return 0, ErrClosed
+		}
+		if len(a.queue) < a.size {
+			break
+		}
+		switch a.overflow {
+		case DropNewest:
+			a.dropped.Add(1)
+			return len(p), nil
+		case DropOldest:
+			a.queue[0] = nil
+			a.queue = a.queue[1:]
+			a.pending--
+			a.dropped.Add(1)
+		default:
+			a.notFull.Wait()
+		}
+	}
+	a.queue = append(a.queue, b)
+	a.pending++
+	a.notEmpty.Signal()
+	return len(p), nil
+}
+
+func (a *AsyncWriter) work() {
+	for {
+		a.mu.Lock()
+		for len(a.queue) == 0 && !a.closed {
+			a.notEmpty.Wait()
+		}
+		if len(a.queue) == 0 {
+			// Closed and drained.
+			a.mu.Unlock()
+			return
+		}
+		b := a.queue[0]
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+		a.notFull.Signal()
+		a.mu.Unlock()
+
+		if _, err := a.w.Write(b); err != nil {
+			a.failed.Add(1)
+			stderr.Printf("AsyncWriter: %v\n", err)
+		}
+
+		a.mu.Lock()
+		a.pending--
+		if a.pending == 0 && a.idle != nil {
+			close(a.idle)
+			a.idle = nil
+		}
+		a.mu.Unlock()
+	}
+}
+
+// Flush waits until all queued records are written.
+// If the underlying writer has a Flush(context.Context) error method, e.g. HttpWriter, it is flushed as well.
+func (a *AsyncWriter) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	if a.pending == 0 {
+		a.mu.Unlock()
+		return flush(ctx, a.w)
+	}
+	if a.idle == nil {
+		a.idle = make(chan struct{})
+	}
+	idle := a.idle
+	a.mu.Unlock()
+
+	select {
+	case <-idle:
+		return flush(ctx, a.w)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting records and waits until queued records are written.
+// Records still queued when ctx is done are lost.
+// If the underlying writer has a Close(context.Context) error method, e.g. HttpWriter,
+// or a Close() error method, e.g. FileWriter, it is closed, otherwise it is flushed.
+func (a *AsyncWriter) Close(ctx context.Context) error {
+	a.mu.Lock()
+	a.closed = true
+	a.notEmpty.Broadcast()
+	a.notFull.Broadcast()
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if c, ok := a.w.(interface{ Close(context.Context) error }); ok {
+		return c.Close(ctx)
+	}
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return flush(ctx, a.w)
+}
+
+// Dropped returns the number of records dropped because the queue was full.
+func (a *AsyncWriter) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// Failed returns the number of records the underlying writer failed to write.
+func (a *AsyncWriter) Failed() uint64 {
+	return a.failed.Load()
+}
+
+// flush flushes writers which buffer records, e.g. HttpWriter batching records.
+func flush(ctx context.Context, w io.Writer) error {
+	if f, ok := w.(interface{ Flush(context.Context) error }); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
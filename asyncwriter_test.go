@@ -0,0 +1,149 @@
+package xlg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateWriter blocks every write until the gate is opened.
+type gateWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{gate: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// waitQueued waits until the worker has taken the first record, so the queue state is predictable.
+func waitQueued(a *AsyncWriter, n int) {
+	for {
+		a.mu.Lock()
+		l := len(a.queue)
+		a.mu.Unlock()
+		if l == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncWriter_overflow(t *testing.T) {
+	tests := []struct {
+		overflow Overflow
+		expected string
+	}{
+		{DropNewest, "123"},
+		{DropOldest, "134"},
+	}
+	for _, tc := range tests {
+		w := newGateWriter()
+		a := NewAsyncWriter(w, 2, 1, tc.overflow)
+		a.Write([]byte("1"))
+		// The worker holds "1" blocked on the gate, the queue has room for 2 records.
+		waitQueued(a, 0)
+		for _, s := range []string{"2", "3", "4"} {
+			if _, err := a.Write([]byte(s)); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+		close(w.gate)
+		check(a.Close(context.Background()))
+
+		if w.String() != tc.expected {
+			t.Errorf("overflow %d: expected %q written, got %q", tc.overflow, tc.expected, w.String())
+		}
+		if a.Dropped() != 1 {
+			t.Errorf("overflow %d: expected 1 dropped record, got %d", tc.overflow, a.Dropped())
+		}
+	}
+}
+
+func TestAsyncWriter_block(t *testing.T) {
+	w := newGateWriter()
+	a := NewAsyncWriter(w, 1, 1, Block)
+	a.Write([]byte("1"))
+	waitQueued(a, 0)
+	a.Write([]byte("2"))
+
+	written := make(chan struct{})
+	go func() {
+		a.Write([]byte("3"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("expected write to block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(w.gate)
+	<-written
+	check(a.Close(context.Background()))
+	if w.String() != "123" || a.Dropped() != 0 {
+		t.Errorf("expected all records written, got %q and %d dropped", w.String(), a.Dropped())
+	}
+}
+
+func TestAsyncWriter_Flush(t *testing.T) {
+	w := newGateWriter()
+	a := NewAsyncWriter(w, 10, 2, DropNewest)
+	a.Write([]byte("1"))
+	a.Write([]byte("2"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded while writer is blocked, got %v", err)
+	}
+
+	close(w.gate)
+	check(a.Flush(context.Background()))
+	if len(w.String()) != 2 {
+		t.Errorf("expected 2 records written after Flush, got %q", w.String())
+	}
+}
+
+func TestAsyncWriter_Close(t *testing.T) {
+	w := newGateWriter()
+	close(w.gate)
+	a := NewAsyncWriter(w, 10, 1, DropNewest)
+	a.Write([]byte("1"))
+	check(a.Close(context.Background()))
+	if w.String() != "1" {
+		t.Errorf("expected queued record written on Close, got %q", w.String())
+	}
+	if _, err := a.Write([]byte("2")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestAsyncWriter_copiesRecord(t *testing.T) {
+	w := newGateWriter()
+	a := NewAsyncWriter(w, 10, 1, DropNewest)
+	p := []byte("1")
+	a.Write(p)
+	p[0] = '2'
+	close(w.gate)
+	check(a.Close(context.Background()))
+	if w.String() != "1" {
+		t.Errorf("expected written record not to change with the caller's buffer, got %q", w.String())
+	}
+}
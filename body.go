@@ -0,0 +1,160 @@
+package xlg
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDecodedBytes limits decompression of a captured body, so a compression bomb does not exhaust memory.
+const maxDecodedBytes = 1 << 20
+
+// captureBody converts a captured body to its logged form according to Content-Encoding and Content-Type of header:
+// compressed bodies are decoded, multipart bodies are summarized, forms are redacted in place,
+// JSON and XML are compacted, binary bodies are replaced with a placeholder and sensitive data is redacted.
+// dropped is the number of bytes which were not captured.
+func captureBody(rd *Redactor, header http.Header, body []byte, dropped int) string {
+	if len(body) == 0 {
+		return string(truncateDropped(body, dropped))
+	}
+	body, complete := decodeBody(header.Get("Content-Encoding"), body, dropped == 0)
+
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(body)
+	}
+	mediaType, params, _ := mime.ParseMediaType(ct)
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return summarizeMultipart(body, params["boundary"], complete)
+	case mediaType == "application/x-www-form-urlencoded":
+		return string(truncateDropped(rd.form(body), dropped))
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return string(truncateDropped(rd.Body(compactXML(body)), dropped))
+	}
+	if isBinary(body, complete) {
+		return binaryPlaceholder(body, len(body)+dropped, complete, mediaType)
+	}
+	body = rd.Body(body)
+	if compact, err := compactJSON(body); err == nil {
+		body = compact
+	}
+	return string(truncateDropped(body, dropped))
+}
+
+// decodeBody decodes gzip and deflate bodies. A body which was not captured completely is decoded as far as possible.
+// It reports whether the decoded body is complete; on failure the body is returned as is.
+func decodeBody(encoding string, body []byte, complete bool) ([]byte, bool) {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// HTTP deflate is zlib-wrapped (RFC 9110 8.4.1.2), but some servers send raw DEFLATE.
+		if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body, complete
+	}
+	if err != nil {
+		return body, complete
+	}
+	defer r.Close()
+	decoded, err := io.ReadAll(io.LimitReader(r, maxDecodedBytes+1))
+	if len(decoded) > maxDecodedBytes {
+		return decoded[:maxDecodedBytes], false
+	}
+	if err != nil {
+		if complete || !errors.Is(err, io.ErrUnexpectedEOF) {
+			return body, complete
+		}
+		return decoded, false
+	}
+	return decoded, complete
+}
+
+type partSummary struct {
+	Name        string `json:"name,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+// summarizeMultipart describes the parts of a multipart body without their contents, which are usually files.
+func summarizeMultipart(body []byte, boundary string, complete bool) string {
+	var parts []partSummary
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				complete = false
+			}
+			break
+		}
+		n, err := io.Copy(io.Discard, p)
+		parts = append(parts, partSummary{
+			Name:        p.FormName(),
+			Filename:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Size:        n,
+		})
+		if err != nil {
+			complete = false
+			break
+		}
+	}
+	b, err := json.Marshal(struct {
+		Parts     []partSummary `json:"xlg_multipart"`
+		Truncated bool          `json:"truncated,omitempty"`
+	}{parts, !complete})
+	if err != nil {
+		stderr.Printf("summarizeMultipart: %v\n", err)
+	}
+	return string(b)
+}
+
+var xmlSpace = regexp.MustCompile(`>\s+<`)
+
+// compactXML removes white space between tags.
+func compactXML(body []byte) []byte {
+	return bytes.TrimSpace(xmlSpace.ReplaceAll(body, []byte("><")))
+}
+
+// isBinary reports whether the body is not UTF-8 text. The last rune of an incomplete body may be cut.
+func isBinary(body []byte, complete bool) bool {
+	if !complete {
+		for i := 0; i < utf8.UTFMax-1 && len(body) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(body); r != utf8.RuneError {
+				break
+			}
+			body = body[:len(body)-1]
+		}
+	}
+	return !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0
+}
+
+// binaryPlaceholder describes a binary body of size bytes, the hash is of the captured bytes only if it is incomplete.
+func binaryPlaceholder(body []byte, size int, complete bool, mediaType string) string {
+	sum := sha256.Sum256(body)
+	hash := "sha256"
+	if !complete {
+		hash = "sha256_prefix"
+	}
+	return fmt.Sprintf("...xlg_binary size=%d %s=%s type=%s...", size, hash, hex.EncodeToString(sum[:]), mediaType)
+}
@@ -0,0 +1,147 @@
+package xlg
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_captureBody(t *testing.T) {
+	gz := func(s string) []byte {
+		b := new(bytes.Buffer)
+		zw := gzip.NewWriter(b)
+		zw.Write([]byte(s))
+		zw.Close()
+		return b.Bytes()
+	}
+	deflate := func(s string) []byte {
+		b := new(bytes.Buffer)
+		zw := zlib.NewWriter(b)
+		zw.Write([]byte(s))
+		zw.Close()
+		return b.Bytes()
+	}
+	rawDeflate := func(s string) []byte {
+		b := new(bytes.Buffer)
+		zw, _ := flate.NewWriter(b, flate.DefaultCompression)
+		zw.Write([]byte(s))
+		zw.Close()
+		return b.Bytes()
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0, 0xff)
+	pngSum := sha256.Sum256(png)
+	// Stored blocks keep the text as is, so a cut body decodes to a known prefix.
+	storedGz := func(s string) []byte {
+		b := new(bytes.Buffer)
+		zw, _ := gzip.NewWriterLevel(b, gzip.NoCompression)
+		zw.Write([]byte(s))
+		zw.Close()
+		return b.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     []byte
+		dropped  int
+		expected string
+	}{
+		{
+			name:     "json is compacted",
+			body:     []byte(`{ "a": 1 }`),
+			expected: `{"a":1}`,
+		},
+		{
+			name:     "gzip",
+			header:   http.Header{"Content-Encoding": {"gzip"}, "Content-Type": {"application/json"}},
+			body:     gz(`{ "a": 1 }`),
+			expected: `{"a":1}`,
+		},
+		{
+			name:     "deflate",
+			header:   http.Header{"Content-Encoding": {"deflate"}},
+			body:     deflate(`{ "a": 1 }`),
+			expected: `{"a":1}`,
+		},
+		{
+			name:     "raw deflate",
+			header:   http.Header{"Content-Encoding": {"deflate"}},
+			body:     rawDeflate("plain text"),
+			expected: "plain text",
+		},
+		{
+			name:     "truncated gzip is decoded as far as possible",
+			header:   http.Header{"Content-Encoding": {"gzip"}},
+			body:     storedGz(strings.Repeat("text ", 1000))[:40],
+			dropped:  100,
+			expected: "text text text text text ...xlg_truncated 100 bytes",
+		},
+		{
+			name:     "form",
+			header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:     []byte("user=john&password=secret&tag=a&tag=b"),
+			expected: "user=john&password=...xlg_redacted...&tag=a&tag=b",
+		},
+		{
+			name:     "xml is compacted",
+			header:   http.Header{"Content-Type": {"application/soap+xml; charset=utf-8"}},
+			body:     []byte("<a>\n  <b>text with  spaces</b>\n</a>\n"),
+			expected: "<a><b>text with  spaces</b></a>",
+		},
+		{
+			name:     "binary",
+			header:   http.Header{"Content-Type": {"image/png"}},
+			body:     png,
+			expected: "...xlg_binary size=12 sha256=" + hex.EncodeToString(pngSum[:]) + " type=image/png...",
+		},
+		{
+			name:     "binary without content type",
+			body:     png,
+			dropped:  8,
+			expected: "...xlg_binary size=20 sha256_prefix=" + hex.EncodeToString(pngSum[:]) + " type=image/png...",
+		},
+		{
+			name:     "text cut in the middle of a rune is not binary",
+			body:     []byte("привет")[:5],
+			dropped:  7,
+			expected: "пр\xd0...xlg_truncated 7 bytes",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := captureBody(DefaultRedactor, tc.header, tc.body, tc.dropped)
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func Test_summarizeMultipart(t *testing.T) {
+	b := new(bytes.Buffer)
+	mw := multipart.NewWriter(b)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("file", "report.pdf")
+	fw.Write(bytes.Repeat([]byte{0xff}, 1000))
+	mw.Close()
+	header := http.Header{"Content-Type": {mw.FormDataContentType()}}
+
+	got := captureBody(DefaultRedactor, header, b.Bytes(), 0)
+	expected := `{"xlg_multipart":[{"name":"title","size":6},` +
+		`{"name":"file","filename":"report.pdf","content_type":"application/octet-stream","size":1000}]}`
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	got = captureBody(DefaultRedactor, header, b.Bytes()[:b.Len()/2], b.Len()/2)
+	if !strings.Contains(got, `"truncated":true`) || !strings.Contains(got, `"name":"title"`) {
+		t.Errorf("expected truncated summary, got %s", got)
+	}
+}
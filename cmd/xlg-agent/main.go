@@ -0,0 +1,73 @@
+// Command xlg-agent ships log files written by xlg.FileWriter to a collector.
+//
+// Every pass it scans the log directory, posts unsent lines one by one in the same way as xlg.HttpWriter
+// and flips NotSentMark to SentMark only after the collector responds with 2xx.
+// Lines the collector rejects with 4xx, e.g. a torn line left by a crash, are marked with RejectedMark and skipped.
+//
+// Usage:
+//
+//	xlg-agent -dir /log/dir -url http://localhost:8080/logserver -header "Authorization: Bearer token"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ostrbor/xlg"
+)
+
+// headers collects repeated -header flags in "Key: Value" form.
+type headers map[string]string
+
+func (h headers) String() string {
+	return fmt.Sprint(map[string]string(h))
+}
+
+func (h headers) Set(s string) error {
+	k, v, ok := strings.Cut(s, ":")
+	if !ok {
+		return fmt.Errorf("expected 'Key: Value', got %q", s)
+	}
+	h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	return nil
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory with log files written by xlg.FileWriter")
+	url := flag.String("url", "", "collector URL")
+	interval := flag.Duration("interval", 5*time.Second, "pause between passes over the log directory")
+	hs := make(headers)
+	flag.Var(hs, "header", "header added to each request, e.g. 'Authorization: Bearer token' (repeatable)")
+	flag.Parse()
+
+	if *url == "" {
+		log.Fatal("-url is required")
+	}
+
+	w := xlg.HttpWriter{URL: *url, Headers: hs}
+	s := xlg.Shipper{Dir: *dir, Send: func(line []byte) error {
+		_, err := w.Write(line)
+		return err
+	}}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+	for {
+		if _, err := s.Ship(); err != nil {
+			log.Printf("ship: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+	}
+}
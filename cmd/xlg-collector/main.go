@@ -0,0 +1,40 @@
+// Command xlg-collector is a reference collector for records posted by xlg.HttpWriter and xlg-agent.
+//
+// POST /records accepts a single record, a JSON array of records or NDJSON, optionally gzip-compressed.
+// Records of a batch are acknowledged individually with xlg.BatchAck.
+// GET /records returns stored records filtered by query parameters:
+// ref, user, env, host, req_path, resp_status, attr=key:value (repeatable), from and to (RFC3339) and limit.
+// The time range applies to the record time, or to the receive time for records without one.
+//
+// Records are stored in a single append-only file, so the whole pipeline can be run locally:
+//
+//	xlg-collector -addr :8080 -db xlg.db
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+)
+
+var stderr = log.New(os.Stderr, "xlg-collector: ", log.Flags())
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	db := flag.String("db", "xlg.db", "file where records are stored")
+	flag.Parse()
+
+	s, err := openStore(*db)
+	if err != nil {
+		stderr.Fatal(err)
+	}
+	defer s.close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/records", &server{store: s})
+	stderr.Printf("listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		stderr.Fatal(err)
+	}
+}
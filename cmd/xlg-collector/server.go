@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ostrbor/xlg"
+)
+
+// maxBodyBytes limits the size of a posted body.
+const maxBodyBytes = 32 << 20
+
+type server struct {
+	store *store
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.post(w, r)
+	case http.MethodGet:
+		s.get(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// post stores a single record, a JSON array of records or records separated by newlines (NDJSON).
+// The body may be compressed with gzip.
+//
+// A single record is either stored or rejected with 400.
+// Records of a batch (array or application/x-ndjson) are acknowledged individually with xlg.BatchAck:
+// invalid records are rejected and the rest are stored, or listed for retry if the store fails.
+func (s *server) post(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	b, err := io.ReadAll(io.LimitReader(body, maxBodyBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(b) > maxBodyBytes {
+		http.Error(w, "body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	raws, batch, err := split(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	batch = batch || strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-ndjson")
+
+	var ack xlg.BatchAck
+	var stored []int
+	records := make([]xlg.Record, 0, len(raws))
+	for i, raw := range raws {
+		rec, err := validate(raw)
+		if err != nil {
+			if !batch {
+				http.Error(w, fmt.Sprintf("record %d: %v", i, err), http.StatusBadRequest)
+				return
+			}
+			ack.Rejected = append(ack.Rejected, xlg.BatchError{Index: i, Error: err.Error()})
+			continue
+		}
+		records = append(records, rec)
+		stored = append(stored, i)
+	}
+	if err := s.store.add(time.Now().UTC(), records); err != nil {
+		if !batch {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		stderr.Printf("store: %v", err)
+		ack.Retry = stored
+	}
+	if !batch {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ack); err != nil {
+		stderr.Printf("encode ack: %v", err)
+	}
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.store.query(f)); err != nil {
+		stderr.Printf("encode query result: %v", err)
+	}
+}
+
+// split returns raw JSON records from a body holding a single object, an array of objects or NDJSON.
+// batch reports whether the body holds more than one record or an array.
+func split(body []byte) (raws []json.RawMessage, batch bool, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, false, fmt.Errorf("empty body")
+	}
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &raws); err != nil {
+			return nil, false, err
+		}
+		return raws, true, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, false, err
+		}
+		raws = append(raws, raw)
+	}
+	return raws, len(raws) > 1, nil
+}
+
+// validate decodes a record rejecting fields unknown to xlg.Record.
+func validate(raw json.RawMessage) (rec xlg.Record, err error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rec); err != nil {
+		return rec, err
+	}
+	if rec.Message == "" {
+		return rec, fmt.Errorf("msg is required")
+	}
+	return rec, nil
+}
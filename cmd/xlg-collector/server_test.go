@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *server {
+	s, err := openStore(filepath.Join(t.TempDir(), "xlg.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.close() })
+	return &server{store: s}
+}
+
+func TestServerPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		header   http.Header
+		status   int
+		expected int
+		ack      string
+	}{
+		{name: "single record", body: `{"msg":"one","ref":"r"}`, status: http.StatusNoContent, expected: 1},
+		{name: "array", body: `[{"msg":"one"},{"msg":"two"}]`, status: http.StatusOK, expected: 2, ack: "{}\n"},
+		{name: "ndjson", body: "{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n", status: http.StatusOK, expected: 2, ack: "{}\n"},
+		{name: "ndjson with one record", body: "{\"msg\":\"one\"}\n", header: http.Header{"Content-Type": {"application/x-ndjson"}}, status: http.StatusOK, expected: 1, ack: "{}\n"},
+		{name: "unknown field", body: `{"msg":"one","unknown":1}`, status: http.StatusBadRequest},
+		{name: "missing msg", body: `{"ref":"r"}`, status: http.StatusBadRequest},
+		{name: "one invalid record in batch", body: "{\"msg\":\"one\"}\n{\"ref\":\"r\"}\n", status: http.StatusOK, expected: 1,
+			ack: `{"rejected":[{"index":1,"error":"msg is required"}]}` + "\n"},
+		{name: "empty body", body: "", status: http.StatusBadRequest},
+		{name: "malformed", body: `{"msg":`, status: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(tc.body))
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			s.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, w.Code, w.Body)
+			}
+			if tc.ack != "" && w.Body.String() != tc.ack {
+				t.Errorf("expected ack %q, got %q", tc.ack, w.Body)
+			}
+			if n := len(s.store.query(filter{})); n != tc.expected {
+				t.Errorf("expected %d stored records, got %d", tc.expected, n)
+			}
+		})
+	}
+}
+
+func TestServerGet(t *testing.T) {
+	s := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(`[{"msg":"one","user":"a"},{"msg":"two","user":"b"}]`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/records?user=b", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var res []entry
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Record.Message != "two" {
+		t.Errorf("expected record 'two', got %+v", res)
+	}
+}
+
+func TestServerPostGzip(t *testing.T) {
+	s := newTestServer(t)
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	zw.Write([]byte("{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n"))
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/records", buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if n := len(s.store.query(filter{})); n != 2 {
+		t.Errorf("expected 2 stored records, got %d", n)
+	}
+}
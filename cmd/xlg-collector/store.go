@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ostrbor/xlg"
+)
+
+// entry is a stored record together with the time it was received by the collector.
+type entry struct {
+	Received time.Time  `json:"received"`
+	Record   xlg.Record `json:"record"`
+}
+
+// eventTime returns the event time of the record,
+// falling back to the receive time for records written before Record.Timestamp was introduced.
+func (e entry) eventTime() time.Time {
+	if e.Record.Timestamp.IsZero() {
+		return e.Received
+	}
+	return e.Record.Timestamp
+}
+
+// store is an append-only file of entries, one JSON object per line.
+// All entries are kept in memory for querying; the file is only read on start.
+type store struct {
+	mu      sync.RWMutex
+	f       *os.File
+	entries []entry
+}
+
+// openStore reads the entries of the file at pathname, creating it if needed.
+// A trailing line without a newline is left by a write interrupted by a crash;
+// its records were not acknowledged, so the line is truncated rather than failing the start.
+func openStore(pathname string) (*store, error) {
+	f, err := os.OpenFile(pathname, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &store{f: f}
+	if err := s.load(pathname); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *store) load(pathname string) error {
+	// bufio.Reader has no line length limit unlike bufio.Scanner, a line holds a record of up to maxBodyBytes.
+	r := bufio.NewReader(s.f)
+	var offset int64
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(b) > 0 {
+				stderr.Printf("%s:%d: truncate partial line of %d bytes", pathname, line, len(b))
+				return s.f.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var e entry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("%s:%d: %w", pathname, line, err)
+		}
+		s.entries = append(s.entries, e)
+		offset += int64(len(b))
+	}
+}
+
+// add persists records and syncs the file, so the records are durable once add returns.
+func (s *store) add(received time.Time, records []xlg.Record) error {
+	var buf []byte
+	entries := make([]entry, 0, len(records))
+	for _, r := range records {
+		e := entry{Received: received, Record: r}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+		entries = append(entries, e)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(buf); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *store) query(f filter) []entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []entry{}
+	for _, e := range s.entries {
+		if f.match(e) {
+			res = append(res, e)
+			if f.limit > 0 && len(res) == f.limit {
+				break
+			}
+		}
+	}
+	return res
+}
+
+func (s *store) close() error {
+	return s.f.Close()
+}
+
+// filter selects entries, empty fields match any value.
+type filter struct {
+	ref, user, env, host, reqPath string
+	respStatus                    int
+	attrs                         map[string]string
+	from, to                      time.Time
+	limit                         int
+}
+
+func (f filter) match(e entry) bool {
+	r := e.Record
+	switch {
+	case f.ref != "" && r.Reference != f.ref:
+	case f.user != "" && r.Username != f.user:
+	case f.env != "" && r.Environment != f.env:
+	case f.host != "" && r.Hostname != f.host:
+	case f.reqPath != "" && r.ReqPath != f.reqPath:
+	case f.respStatus != 0 && r.RespStatus != f.respStatus:
+	case !f.from.IsZero() && e.eventTime().Before(f.from):
+	case !f.to.IsZero() && !e.eventTime().Before(f.to):
+	default:
+		for k, v := range f.attrs {
+			if got, ok := r.Attributes[k]; !ok || (v != "" && got != v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// parseFilter reads a filter from query parameters.
+// Attributes are given as repeated attr=key:value, attr=key matches any value of the key.
+// Time range bounds from and to are in RFC3339 format, from is inclusive and to is exclusive.
+func parseFilter(q map[string][]string) (f filter, err error) {
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	f.ref = get("ref")
+	f.user = get("user")
+	f.env = get("env")
+	f.host = get("host")
+	f.reqPath = get("req_path")
+	if v := get("resp_status"); v != "" {
+		if f.respStatus, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("resp_status: %w", err)
+		}
+	}
+	for _, a := range q["attr"] {
+		if f.attrs == nil {
+			f.attrs = make(map[string]string)
+		}
+		k, v, _ := strings.Cut(a, ":")
+		f.attrs[k] = v
+	}
+	if v := get("from"); v != "" {
+		if f.from, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("from: %w", err)
+		}
+	}
+	if v := get("to"); v != "" {
+		if f.to, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("to: %w", err)
+		}
+	}
+	if v := get("limit"); v != "" {
+		if f.limit, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("limit: %w", err)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ostrbor/xlg"
+)
+
+func TestStore(t *testing.T) {
+	pathname := filepath.Join(t.TempDir(), "xlg.db")
+	s, err := openStore(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := time.Date(2023, 9, 26, 17, 45, 53, 0, time.UTC)
+	records := []xlg.Record{
+		{Message: "first", Reference: "ref1", Username: "user", Attributes: map[string]string{"job": "import"}},
+		{Message: "second", Reference: "ref2", ReqPath: "/api", RespStatus: 500},
+		{Message: "replayed", Timestamp: received.Add(-48 * time.Hour)},
+	}
+	if err := s.add(received, records); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening the store reads records persisted on disk.
+	s, err = openStore(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "no filter", query: "", expected: []string{"first", "second", "replayed"}},
+		{name: "by ref", query: "ref=ref2", expected: []string{"second"}},
+		{name: "by user", query: "user=user", expected: []string{"first"}},
+		{name: "by path and status", query: "req_path=/api&resp_status=500", expected: []string{"second"}},
+		{name: "by attribute key and value", query: "attr=job:import", expected: []string{"first"}},
+		{name: "by attribute key", query: "attr=job", expected: []string{"first"}},
+		{name: "by attribute value mismatch", query: "attr=job:export", expected: nil},
+		{name: "in time range", query: "from=2023-09-26T00:00:00Z&to=2023-09-27T00:00:00Z", expected: []string{"first", "second"}},
+		{name: "out of time range", query: "from=2023-09-27T00:00:00Z", expected: nil},
+		{name: "by record time", query: "to=2023-09-25T00:00:00Z", expected: []string{"replayed"}},
+		{name: "limit", query: "limit=1", expected: []string{"first"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tc.query)
+			f, err := parseFilter(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := s.query(f)
+			if len(res) != len(tc.expected) {
+				t.Fatalf("expected %d records, got %d", len(tc.expected), len(res))
+			}
+			for i, e := range res {
+				if e.Record.Message != tc.expected[i] {
+					t.Errorf("expected msg %q, got %q", tc.expected[i], e.Record.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestStore_reopen(t *testing.T) {
+	pathname := filepath.Join(t.TempDir(), "xlg.db")
+	s, err := openStore(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := strings.Repeat("x", 2<<20)
+	received := time.Date(2023, 9, 26, 17, 45, 53, 0, time.UTC)
+	if err := s.add(received, []xlg.Record{{Message: large}}); err != nil {
+		t.Fatal(err)
+	}
+	check := func(msgs ...string) {
+		t.Helper()
+		res := s.query(filter{})
+		if len(res) != len(msgs) {
+			t.Fatalf("expected %d records, got %d", len(msgs), len(res))
+		}
+		for i, e := range res {
+			if e.Record.Message != msgs[i] {
+				t.Errorf("expected msg of %d bytes, got %d bytes", len(msgs[i]), len(e.Record.Message))
+			}
+		}
+	}
+
+	t.Run("after a large record", func(t *testing.T) {
+		s.close()
+		if s, err = openStore(pathname); err != nil {
+			t.Fatal(err)
+		}
+		check(large)
+	})
+
+	t.Run("after a torn last line", func(t *testing.T) {
+		s.close()
+		f, err := os.OpenFile(pathname, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(`{"received":"2023-09-26T17:45:53Z","record":{"msg":"to`)
+		f.Close()
+		if s, err = openStore(pathname); err != nil {
+			t.Fatal(err)
+		}
+		check(large)
+
+		// The torn line is truncated, so records added after it are read on the next start.
+		if err := s.add(received, []xlg.Record{{Message: "next"}}); err != nil {
+			t.Fatal(err)
+		}
+		s.close()
+		if s, err = openStore(pathname); err != nil {
+			t.Fatal(err)
+		}
+		check(large, "next")
+	})
+	s.close()
+}
+
+func TestParseFilterError(t *testing.T) {
+	for _, query := range []string{"resp_status=ok", "from=yesterday", "limit=all"} {
+		q, _ := url.ParseQuery(query)
+		if _, err := parseFilter(q); err == nil {
+			t.Errorf("expected an error for %q, but got nil", query)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package xlg
+
+import (
+	"context"
+	"net/http"
+)
+
+// DefaultRefHeader is the header which carries the record Reference between services unless changed with SetRefHeader.
+const DefaultRefHeader = "X-Request-ID"
+
+type ctxKey int
+
+const (
+	refKey ctxKey = iota
+	traceKey
+)
+
+// ContextWithRef returns a copy of ctx carrying the reference,
+// so records created with FromContext down the call chain share it.
+func ContextWithRef(ctx context.Context, ref string) context.Context {
+	return context.WithValue(ctx, refKey, ref)
+}
+
+// RefFromContext returns the reference stored in ctx or an empty string.
+func RefFromContext(ctx context.Context) string {
+	ref, _ := ctx.Value(refKey).(string)
+	return ref
+}
+
+// FromContext creates a Record with the reference and the active span stored in ctx.
+// A new reference is generated if there is none.
+func FromContext(ctx context.Context) Record {
+	return std.FromContext(ctx)
+}
+
+// SetRefHeader sets the header carrying the reference for the default Logger, e.g. "X-Correlation-ID".
+func SetRefHeader(name string) {
+	std.SetRefHeader(name)
+}
+
+// InjectRef sets the header carrying the reference on an outgoing request
+// to the reference stored in the request context. Transport does it automatically.
+func InjectRef(req *http.Request) {
+	std.InjectRef(req)
+}
+
+func (l *Logger) FromContext(ctx context.Context) Record {
+	r := l.New()
+	if ref := RefFromContext(ctx); ref != "" {
+		r.Reference = ref
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		r = r.Trace(tc)
+	}
+	return r
+}
+
+func (l *Logger) SetRefHeader(name string) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	l.c.refHeader = name
+}
+
+func (l *Logger) refHeader() string {
+	l.c.mu.RLock()
+	defer l.c.mu.RUnlock()
+	if l.c.refHeader == "" {
+		return DefaultRefHeader
+	}
+	return l.c.refHeader
+}
+
+func (l *Logger) InjectRef(req *http.Request) {
+	if ref := RefFromContext(req.Context()); ref != "" {
+		req.Header.Set(l.refHeader(), ref)
+	}
+}
@@ -0,0 +1,46 @@
+package xlg
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestContextRef(t *testing.T) {
+	ctx := context.Background()
+	if RefFromContext(ctx) != "" {
+		t.Error("expected no reference in an empty context")
+	}
+	if FromContext(ctx).Reference == "" {
+		t.Error("expected a new reference when context has none")
+	}
+
+	ctx = ContextWithRef(ctx, "ref")
+	if RefFromContext(ctx) != "ref" {
+		t.Errorf("expected reference %q, got %q", "ref", RefFromContext(ctx))
+	}
+	if FromContext(ctx).Reference != "ref" {
+		t.Errorf("expected record reference %q, got %q", "ref", FromContext(ctx).Reference)
+	}
+}
+
+func TestRefHeader(t *testing.T) {
+	l := NewLogger(new(bytes.Buffer))
+	l.SetRefHeader("X-Correlation-ID")
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	req.Header.Set("X-Correlation-ID", "incoming")
+	if r := l.Request(req); r.Reference != "incoming" {
+		t.Errorf("expected reference from the header, got %q", r.Reference)
+	}
+
+	out, _ := http.NewRequestWithContext(ContextWithRef(context.Background(), "outgoing"), http.MethodGet, "https://example.com", nil)
+	l.InjectRef(out)
+	if out.Header.Get("X-Correlation-ID") != "outgoing" {
+		t.Errorf("expected reference in the header, got %q", out.Header.Get("X-Correlation-ID"))
+	}
+	if out.Header.Get(DefaultRefHeader) != "" {
+		t.Error("expected the default header not to be set")
+	}
+}
@@ -1,25 +1,116 @@
 package xlg
 
 import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	// Constant FileFormat is exported for use in xlg-agent to facilitate log file identification.
 	FileFormat = "2006-01-02"
+	// HourlyFileFormat names files of FileWriter with Hourly set.
+	HourlyFileFormat = "2006-01-02T15"
 
 	// The presence of NotSentMark at the beginning of a line serves as an indicator
 	// that the line has not been transmitted to the collector.
 	NotSentMark = '-'
+
+	// SentMark replaces NotSentMark once the line is delivered to the collector.
+	// It has the same length as NotSentMark, so the mark can be flipped in place.
+	SentMark = '+'
+
+	// RejectedMark replaces NotSentMark of a line which the collector rejected permanently, e.g. a torn line
+	// left by a crash, so it neither blocks later lines nor is sent again.
+	RejectedMark = '!'
+
+	// Retention and compression run in background at most once per housekeepInterval.
+	housekeepInterval = time.Minute
+
+	defaultSyncInterval = 100 * time.Millisecond
+
+	// LockFile is the file in Dir locked by FileWriter with Lock set, it is not a log file.
+	LockFile = ".lock"
+)
+
+// SyncPolicy is a policy of FileWriter for flushing written records to stable storage with fsync.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves flushing to the OS, records written within the last seconds may be lost on power loss.
+	SyncNever SyncPolicy = iota
+	// SyncAlways flushes every record before Write returns, which is the slowest but loses nothing.
+	SyncAlways
+	// SyncInterval flushes records in background every FileWriter.SyncInterval.
+	SyncInterval
 )
 
+// FileWriter appends records to a file per day named with FileFormat, or per hour named with HourlyFileFormat.
+// Names are in local time unless Location is set. Processes in different time zones or sharing a directory
+// across DST changes should use the same Location, preferably time.UTC.
+//
+// With MaxSize set a period is split into numbered files: 2006-01-02, 2006-01-02.1, 2006-01-02.2 and so on.
+// With Compress set files of past periods are gzipped once all their lines are sent, e.g. 2006-01-02.1.gz.
+// MaxAge and MaxTotalSize remove the oldest files regardless of whether they are sent, so the disk does not fill up
+// during a long collector outage. The file being written is never removed.
+//
+// By default the file is opened and closed for every record. With KeepOpen set the descriptor is reused
+// and the file is reopened when the period rolls over or when the file is removed or renamed by another process.
+// Close should be called on shutdown to flush and close the file.
+//
+// The mutex protects writers of one process only. Processes sharing Dir should set Lock,
+// so that every write, including the choice of the file, holds an advisory flock on LockFile
+// and lines of different processes do not interleave.
 type FileWriter struct {
 	mu sync.Mutex
 	// Dir is a directory where log files are stored.
 	Dir string
+
+	MaxSize      int64         // Max bytes in a file, no limit if zero
+	MaxAge       time.Duration // Files of periods older than MaxAge are removed, no limit if zero
+	MaxTotalSize int64         // Max bytes of all files in Dir, no limit if zero
+	Compress     bool          // Gzip sent files of past periods
+
+	Location *time.Location // Time zone of file names, nil stands for local time
+	Hourly   bool           // A file per hour instead of a file per day
+
+	KeepOpen     bool          // Reuse the descriptor of the file being written
+	Sync         SyncPolicy    // Durability of written records, SyncNever by default
+	SyncInterval time.Duration // Period of SyncInterval policy, 100ms if zero
+
+	// Lock takes an advisory lock on LockFile in Dir for every write and for housekeeping,
+	// so processes sharing Dir do not compress or remove the same files. It is a no-op on platforms without flock.
+	Lock bool
+
+	// period and seq identify the file being written.
+	period string
+	seq    int
+
+	// lockf is the open LockFile.
+	lockf *os.File
+
+	// f is the file kept open, fi identifies it to detect its removal.
+	f  *os.File
+	fi os.FileInfo
+
+	// last is the pathname of the last write, dirty tells that it is not synced yet by SyncInterval policy.
+	last     string
+	dirty    bool
+	syncOnce sync.Once
+	stop     chan struct{}
+
+	housekept    time.Time
+	housekeeping atomic.Bool
 }
 
 func (w *FileWriter) Write(p []byte) (n int, err error) {
@@ -30,25 +121,299 @@ func (w *FileWriter) Write(p []byte) (n int, err error) {
 	}
 	line := append([]byte{NotSentMark}, p...)
 
-	pathname := path.Join(w.Dir, filename())
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	err = appendToFile(pathname, line)
+	if w.Lock {
+		if err := w.lock(); err != nil {
+			return 0, err
+		}
+		defer w.unlock()
+	}
+	pathname := path.Join(w.Dir, w.current(int64(len(line))))
+	if w.KeepOpen {
+		err = w.appendToOpenFile(pathname, line)
+	} else {
+		err = appendToFile(pathname, line, w.Sync == SyncAlways)
+	}
+	if err == nil {
+		// A failed write leaves nothing to sync, and the file may not exist.
+		w.last, w.dirty = pathname, w.Sync == SyncInterval
+	}
+	if w.Sync == SyncInterval {
+		w.syncOnce.Do(func() {
+			w.stop = make(chan struct{})
+			go w.syncer(w.stop)
+		})
+	}
+	if w.MaxAge > 0 || w.MaxTotalSize > 0 || w.Compress {
+		if now := time.Now(); now.Sub(w.housekept) >= housekeepInterval && w.housekeeping.CompareAndSwap(false, true) {
+			w.housekept = now
+			go func() {
+				defer w.housekeeping.Store(false)
+				if err := w.Housekeep(); err != nil {
+					stderr.Printf("FileWriter housekeep: %v\n", err)
+				}
+			}()
+		}
+	}
 	return len(line), err
 }
 
+// current returns the name of the file for a line of n bytes, rotating the file if it would exceed MaxSize.
+// w.mu must be held.
+func (w *FileWriter) current(n int64) string {
+	period := w.periodOf(time.Now())
+	if period != w.period {
+		// Files of the period may be left by a previous run.
+		w.period, w.seq = period, 0
+		if files, err := listLogFiles(w.Dir); err == nil {
+			for _, f := range files {
+				if f.Period == period {
+					w.seq = max(w.seq, f.Seq)
+				}
+			}
+		}
+	}
+	name := logName(w.period, w.seq)
+	// Files may be rotated by other processes as well, so the first file with room is taken.
+	for w.MaxSize > 0 {
+		fi, err := os.Stat(path.Join(w.Dir, name))
+		if err != nil || fi.Size() == 0 || fi.Size()+n <= w.MaxSize {
+			break
+		}
+		w.seq++
+		name = logName(w.period, w.seq)
+	}
+	return name
+}
+
+// appendToOpenFile writes the line to the kept open file, opening pathname if the file is not open yet,
+// belongs to another period or was removed. w.mu must be held.
+func (w *FileWriter) appendToOpenFile(pathname string, line []byte) error {
+	if w.f != nil {
+		// The file may be removed or renamed externally, e.g. by logrotate, writes to it would be lost.
+		if fi, err := os.Stat(pathname); w.f.Name() != pathname || err != nil || !os.SameFile(fi, w.fi) {
+			w.closeFile()
+		}
+	}
+	if w.f == nil {
+		f, err := os.OpenFile(pathname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.f, w.fi = f, fi
+	}
+	if _, err := w.f.Write(line); err != nil {
+		return err
+	}
+	if w.Sync == SyncAlways {
+		return w.f.Sync()
+	}
+	return nil
+}
+
+// closeFile syncs and closes the kept open file, w.mu must be held.
+func (w *FileWriter) closeFile() {
+	if w.f == nil {
+		return
+	}
+	if w.dirty && w.last == w.f.Name() {
+		if err := w.f.Sync(); err != nil {
+			stderr.Printf("FileWriter sync: %v\n", err)
+		}
+		w.dirty = false
+	}
+	if err := w.f.Close(); err != nil {
+		stderr.Printf("FileWriter close: %v\n", err)
+	}
+	w.f, w.fi = nil, nil
+}
+
+// syncer flushes written records every SyncInterval until Close is called.
+func (w *FileWriter) syncer(stop <-chan struct{}) {
+	interval := w.SyncInterval
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		w.mu.Lock()
+		if err := w.sync(); err != nil {
+			stderr.Printf("FileWriter sync: %v\n", err)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// sync flushes the file of the last write if there are unsynced records, w.mu must be held.
+func (w *FileWriter) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	if w.f != nil && w.f.Name() == w.last {
+		return w.f.Sync()
+	}
+	// fsync of any descriptor of the file flushes data written via other descriptors.
+	f, err := os.OpenFile(w.last, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// Close flushes records pending SyncInterval, closes the kept open file and stops the background sync.
+// The writer may be used after Close, but the background sync is not restarted.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	err := w.sync()
+	if w.f != nil {
+		if cerr := w.f.Close(); err == nil {
+			err = cerr
+		}
+		w.f, w.fi = nil, nil
+	}
+	if w.lockf != nil {
+		if cerr := w.lockf.Close(); err == nil {
+			err = cerr
+		}
+		w.lockf = nil
+	}
+	return err
+}
+
+// lock takes the lock shared by processes writing to Dir, w.mu must be held.
+func (w *FileWriter) lock() error {
+	if w.lockf == nil {
+		f, err := os.OpenFile(path.Join(w.Dir, LockFile), os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		w.lockf = f
+	}
+	return lockFile(w.lockf)
+}
+
+func (w *FileWriter) unlock() {
+	if err := unlockFile(w.lockf); err != nil {
+		stderr.Printf("FileWriter unlock: %v\n", err)
+	}
+}
+
+// Housekeep compresses sent files of past periods and removes files exceeding MaxAge and MaxTotalSize.
+// It is called by Write in background, so there is no need to call it unless nothing is written for a long time.
+func (w *FileWriter) Housekeep() error {
+	w.mu.Lock()
+	current := logName(w.period, w.seq)
+	if w.Lock {
+		// w.mu is held as well, since writes of this process share the descriptor and the flock does not exclude them.
+		defer w.mu.Unlock()
+		if err := w.lock(); err != nil {
+			return err
+		}
+		defer w.unlock()
+	} else {
+		w.mu.Unlock()
+	}
+	now := time.Now()
+	period := w.periodOf(now)
+
+	files, err := listLogFiles(w.Dir)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	// The latest file is written next even if this writer has not written yet.
+	latest := files[len(files)-1].Name
+	if w.Compress {
+		for i, f := range files {
+			if f.Compressed || f.Period >= period {
+				continue
+			}
+			sent, err := allSent(path.Join(w.Dir, f.Name))
+			if err != nil || !sent {
+				continue
+			}
+			if err := gzipFile(path.Join(w.Dir, f.Name)); errors.Is(err, fs.ErrExist) || errors.Is(err, fs.ErrNotExist) {
+				// Another writer is compressing the file or has done it.
+				continue
+			} else if err != nil {
+				return err
+			}
+			files[i].Name, files[i].Compressed = f.Name+".gz", true
+		}
+	}
+
+	var kept []LogFile
+	for _, f := range files {
+		if w.MaxAge > 0 && f.Name != current && f.Name != latest {
+			if end, err := f.End(w.location()); err == nil && now.Sub(end) > w.MaxAge {
+				if err := os.Remove(path.Join(w.Dir, f.Name)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		kept = append(kept, f)
+	}
+
+	if w.MaxTotalSize > 0 {
+		sizes := make([]int64, len(kept))
+		var total int64
+		for i, f := range kept {
+			if fi, err := os.Stat(path.Join(w.Dir, f.Name)); err == nil {
+				sizes[i] = fi.Size()
+				total += sizes[i]
+			}
+		}
+		for i := 0; i < len(kept) && total > w.MaxTotalSize; i++ {
+			if kept[i].Name == current || kept[i].Name == latest {
+				continue
+			}
+			if err := os.Remove(path.Join(w.Dir, kept[i].Name)); err != nil {
+				return err
+			}
+			total -= sizes[i]
+		}
+	}
+	return nil
+}
+
 // The file open and close operations in this func are fast enough,
 // it takes only ~0.01ms to execute this func as per benchmark tests.
-// For the sake of simplicity and code readability decided not to reuse the open file descriptor.
-func appendToFile(pathname string, line []byte) (err error) {
+// For the sake of simplicity the open file descriptor is not reused unless FileWriter.KeepOpen is set.
+func appendToFile(pathname string, line []byte, sync bool) (err error) {
 	f, err := os.OpenFile(pathname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	_, err = f.Write(line)
 	if err != nil {
+		f.Close()
 		return err
 	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
 	// It's important to close the file to prevent 'too many open files' errors,
 	// as there is a limit on open file descriptors.
 	if err := f.Close(); err != nil {
@@ -57,6 +422,157 @@ func appendToFile(pathname string, line []byte) (err error) {
 	return nil
 }
 
-func filename() string {
-	return time.Now().Format(FileFormat)
+func (w *FileWriter) location() *time.Location {
+	if w.Location == nil {
+		return time.Local
+	}
+	return w.Location
+}
+
+// periodOf returns the name of the period of t, which is the name of its first file.
+func (w *FileWriter) periodOf(t time.Time) string {
+	if w.Hourly {
+		return t.In(w.location()).Format(HourlyFileFormat)
+	}
+	return t.In(w.location()).Format(FileFormat)
+}
+
+// LogFile is a file written by FileWriter, its name consists of the period named with FileFormat or HourlyFileFormat,
+// the sequence number if the period is split by FileWriter.MaxSize and .gz suffix if the file is compressed,
+// e.g. 2006-01-02, 2006-01-02T15.2 or 2006-01-02.1.gz.
+type LogFile struct {
+	Name       string
+	Period     string
+	Seq        int
+	Compressed bool
+}
+
+// ParseLogFile parses the name of a file written by FileWriter, it is useful for tooling which discovers log files.
+func ParseLogFile(name string) (f LogFile, ok bool) {
+	f.Name = name
+	rest, gz := strings.CutSuffix(name, ".gz")
+	f.Compressed = gz
+	period, seq, numbered := strings.Cut(rest, ".")
+	if _, err := time.Parse(FileFormat, period); err != nil {
+		if _, err := time.Parse(HourlyFileFormat, period); err != nil {
+			return f, false
+		}
+	}
+	f.Period = period
+	if numbered {
+		n, err := strconv.Atoi(seq)
+		if err != nil || n <= 0 || strconv.Itoa(n) != seq {
+			return f, false
+		}
+		f.Seq = n
+	}
+	return f, true
+}
+
+// Start returns the beginning of the period of the file in loc, which must be the FileWriter.Location it was written in.
+func (f LogFile) Start(loc *time.Location) (time.Time, error) {
+	if len(f.Period) == len(HourlyFileFormat) {
+		return time.ParseInLocation(HourlyFileFormat, f.Period, loc)
+	}
+	return time.ParseInLocation(FileFormat, f.Period, loc)
+}
+
+// End returns the end of the period of the file in loc.
+func (f LogFile) End(loc *time.Location) (time.Time, error) {
+	start, err := f.Start(loc)
+	if len(f.Period) == len(HourlyFileFormat) {
+		return start.Add(time.Hour), err
+	}
+	return start.AddDate(0, 0, 1), err
+}
+
+func logName(period string, seq int) string {
+	if seq == 0 {
+		return period
+	}
+	return period + "." + strconv.Itoa(seq)
+}
+
+// listLogFiles returns files written by FileWriter in dir in the order they were written.
+func listLogFiles(dir string) ([]LogFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []LogFile
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if f, ok := ParseLogFile(e.Name()); ok {
+			files = append(files, f)
+		}
+	}
+	// File formats start with the year, so lexical order of periods is chronological.
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Period != files[j].Period {
+			return files[i].Period < files[j].Period
+		}
+		return files[i].Seq < files[j].Seq
+	})
+	return files, nil
+}
+
+// allSent reports whether every line of the file is complete and marked with SentMark or RejectedMark.
+func allSent(pathname string) (bool, error) {
+	f, err := os.Open(pathname)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without a newline is still being written.
+			return len(line) == 0, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if line[0] != SentMark && line[0] != RejectedMark {
+			return false, nil
+		}
+	}
+}
+
+// gzipFile replaces the file with its gzipped copy with .gz suffix.
+// The copy is written under a temporary name, so a crash leaves either the original or the complete copy.
+func gzipFile(pathname string) (err error) {
+	src, err := os.Open(pathname)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := pathname + ".gz.tmp"
+	// O_EXCL keeps a temporary file of another writer intact.
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(tmp)
+		}
+	}()
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, pathname+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(pathname)
 }
@@ -1,10 +1,16 @@
 package xlg
 
 import (
+	"compress/gzip"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestFileWriter(t *testing.T) {
@@ -22,7 +28,7 @@ func TestFileWriter(t *testing.T) {
 			t.Errorf("expected %d bytes written, but wrote %d bytes", len(logLine)+1, n)
 		}
 
-		filePath := filepath.Join(tempDir, filename())
+		filePath := filepath.Join(tempDir, writer.periodOf(time.Now()))
 		fileContent, err := os.ReadFile(filePath)
 		if err != nil {
 			t.Fatalf("error reading log file: %v", err)
@@ -43,7 +49,7 @@ func TestFileWriter(t *testing.T) {
 			t.Errorf("expected %d bytes written, but wrote %d bytes", len(logLine)+2, n)
 		}
 
-		filePath := filepath.Join(tempDir, filename())
+		filePath := filepath.Join(tempDir, writer.periodOf(time.Now()))
 		fileContent, err := os.ReadFile(filePath)
 		if err != nil {
 			t.Fatalf("error reading log file: %v", err)
@@ -56,11 +62,361 @@ func TestFileWriter(t *testing.T) {
 	})
 }
 
+func TestFileWriter_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	w := &FileWriter{Dir: dir, MaxSize: 20}
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte(`{"msg":"test"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each line takes 16 bytes, so every file holds one line.
+	day := w.periodOf(time.Now())
+	for _, name := range []string{day, day + ".1", day + ".2"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected file %s, got %v", name, err)
+		}
+		if string(content) != "-{\"msg\":\"test\"}\n" {
+			t.Errorf("expected one line in %s, got %q", name, content)
+		}
+	}
+
+	// A new writer continues with the last file of the day.
+	w = &FileWriter{Dir: dir, MaxSize: 20}
+	if _, err := w.Write([]byte(`{"msg":"test"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, day+".3")); err != nil {
+		t.Errorf("expected file %s, got %v", day+".3", err)
+	}
+}
+
+func TestParseLogFile(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+		seq  int
+		gz   bool
+	}{
+		{"2024-01-02", true, 0, false},
+		{"2024-01-02.3", true, 3, false},
+		{"2024-01-02.gz", true, 0, true},
+		{"2024-01-02.12.gz", true, 12, true},
+		{"2024-01-02T15", true, 0, false},
+		{"2024-01-02T15.2.gz", true, 2, true},
+		{"2024-01-02T25", false, 0, false},
+		{"2024-01-02.0", false, 0, false},
+		{"2024-01-02.03", false, 0, false},
+		{"2024-01-02.gz.tmp", false, 0, false},
+		{"2024-01-02.log", false, 0, false},
+		{"app.log", false, 0, false},
+	}
+	for _, tt := range tests {
+		f, ok := ParseLogFile(tt.name)
+		if ok != tt.ok || ok && (f.Period[:10] != "2024-01-02" || f.Seq != tt.seq || f.Compressed != tt.gz) {
+			t.Errorf("%s: expected ok %v, seq %d, gz %v, got %v, %+v", tt.name, tt.ok, tt.seq, tt.gz, ok, f)
+		}
+	}
+}
+
+func TestFileWriter_HourlyUTC(t *testing.T) {
+	dir := t.TempDir()
+	w := &FileWriter{Dir: dir, Location: time.UTC, Hourly: true}
+	before := time.Now().UTC().Format(HourlyFileFormat)
+	if _, err := w.Write([]byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().UTC().Format(HourlyFileFormat)
+
+	names, err := logFiles(dir)
+	check(err)
+	if len(names) != 1 || names[0] != before && names[0] != after {
+		t.Errorf("expected file %s, got %v", after, names)
+	}
+}
+
+func TestLogFile_End(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	tests := []struct {
+		name     string
+		expected time.Time
+	}{
+		{"2024-01-02", time.Date(2024, 1, 3, 0, 0, 0, 0, loc)},
+		{"2024-01-02.1.gz", time.Date(2024, 1, 3, 0, 0, 0, 0, loc)},
+		{"2024-01-02T23", time.Date(2024, 1, 3, 0, 0, 0, 0, loc)},
+		{"2024-01-02T05.3", time.Date(2024, 1, 2, 6, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		f, ok := ParseLogFile(tt.name)
+		if !ok {
+			t.Fatalf("expected %s to be parsed", tt.name)
+		}
+		end, err := f.End(loc)
+		if err != nil || !end.Equal(tt.expected) {
+			t.Errorf("%s: expected %v, got %v, %v", tt.name, tt.expected, end, err)
+		}
+	}
+}
+
+func Test_logFilesOrder(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2024-01-02.10", "2024-01-02.2", "2024-01-02", "2024-01-01.1.gz", "2024-01-01", "2024-01-01T23", ".lock"} {
+		check(os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	names, err := logFiles(dir)
+	check(err)
+	expected := "2024-01-01 2024-01-01T23 2024-01-02 2024-01-02.2 2024-01-02.10"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestFileWriter_Housekeep(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().AddDate(0, 0, -10).Format(FileFormat)
+	yesterday := time.Now().AddDate(0, 0, -1).Format(FileFormat)
+	today := (&FileWriter{}).periodOf(time.Now())
+	files := map[string]string{
+		old:              "+sent\n",
+		yesterday:        "+sent\n+sent\n",
+		yesterday + ".1": "+sent\n-unsent\n",
+		today:            "-unsent\n",
+	}
+	for name, content := range files {
+		check(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	w := &FileWriter{Dir: dir, MaxAge: 5 * 24 * time.Hour, Compress: true}
+	check(w.Housekeep())
+
+	names, err := os.ReadDir(dir)
+	check(err)
+	var got []string
+	for _, e := range names {
+		got = append(got, e.Name())
+	}
+	expected := []string{today, yesterday + ".1", yesterday + ".gz"}
+	sort.Strings(expected)
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	f, err := os.Open(filepath.Join(dir, yesterday+".gz"))
+	check(err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	check(err)
+	content, err := io.ReadAll(zr)
+	check(err)
+	if string(content) != files[yesterday] {
+		t.Errorf("expected compressed %q, got %q", files[yesterday], content)
+	}
+
+	// Total size limit removes the oldest files first.
+	w = &FileWriter{Dir: dir, MaxTotalSize: 20}
+	check(w.Housekeep())
+	for _, name := range []string{yesterday + ".gz", yesterday + ".1"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, today)); err != nil {
+		t.Errorf("expected the latest file to be kept, got %v", err)
+	}
+}
+
+// Writers with their own lock descriptors stand for processes housekeeping a shared Dir at once.
+func TestFileWriter_HousekeepLock(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("+sent\n", 10000)
+	var past []string
+	for i := 1; i <= 5; i++ {
+		name := time.Now().AddDate(0, 0, -i).Format(FileFormat)
+		check(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		past = append(past, name)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		w := &FileWriter{Dir: dir, Lock: true, Compress: true}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.Close()
+			for j := 0; j < 5; j++ {
+				if err := w.Housekeep(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range past {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", name, err)
+		}
+		f, err := os.Open(filepath.Join(dir, name+".gz"))
+		check(err)
+		zr, err := gzip.NewReader(f)
+		check(err)
+		got, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil || string(got) != content {
+			t.Errorf("expected intact %s.gz, got %d bytes, %v", name, len(got), err)
+		}
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) > 0 {
+		t.Errorf("expected no temporary files, got %v", tmps)
+	}
+}
+
+func TestFileWriter_KeepOpen(t *testing.T) {
+	dir := t.TempDir()
+	w := &FileWriter{Dir: dir, KeepOpen: true, Sync: SyncInterval, SyncInterval: time.Millisecond}
+	defer w.Close()
+	pathname := filepath.Join(dir, w.periodOf(time.Now()))
+
+	write := func(msg string) {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("one")
+	write("two")
+	content, err := os.ReadFile(pathname)
+	check(err)
+	if string(content) != "-one\n-two\n" {
+		t.Errorf("expected both lines, got %q", content)
+	}
+
+	// The file is reopened after it is moved away by another process.
+	check(os.Rename(pathname, pathname+".moved"))
+	write("three")
+	content, err = os.ReadFile(pathname)
+	check(err)
+	if string(content) != "-three\n" {
+		t.Errorf("expected line in a new file, got %q", content)
+	}
+
+	check(w.Close())
+	if w.f != nil {
+		t.Error("expected file to be closed")
+	}
+}
+
+func TestFileWriter_SyncAlways(t *testing.T) {
+	for _, keepOpen := range []bool{false, true} {
+		dir := t.TempDir()
+		w := &FileWriter{Dir: dir, KeepOpen: keepOpen, Sync: SyncAlways}
+		if _, err := w.Write([]byte("one")); err != nil {
+			t.Fatal(err)
+		}
+		check(w.Close())
+		content, err := os.ReadFile(filepath.Join(dir, w.periodOf(time.Now())))
+		check(err)
+		if string(content) != "-one\n" {
+			t.Errorf("keepOpen %v: expected line, got %q", keepOpen, content)
+		}
+	}
+}
+
+func TestFileWriter_SyncIntervalFailedWrite(t *testing.T) {
+	w := &FileWriter{Dir: filepath.Join(t.TempDir(), "missing"), Sync: SyncInterval}
+	if _, err := w.Write([]byte("one")); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	// Nothing was written, so there is nothing to sync.
+	if w.dirty || w.last != "" {
+		t.Errorf("expected no pending sync, got dirty %v of %q", w.dirty, w.last)
+	}
+	check(w.Close())
+}
+
+// Writers with their own lock descriptors stand for processes sharing Dir.
+func TestFileWriter_Lock(t *testing.T) {
+	dir := t.TempDir()
+	const maxSize = 64 << 10
+	line := strings.Repeat("x", 10000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		w := &FileWriter{Dir: dir, Lock: true, MaxSize: maxSize}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.Close()
+			for j := 0; j < 25; j++ {
+				if _, err := w.Write([]byte(line)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	names, err := logFiles(dir)
+	check(err)
+	lines := 0
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		check(err)
+		if len(content) > maxSize {
+			t.Errorf("expected %s within %d bytes, got %d", name, maxSize, len(content))
+		}
+		for _, l := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			if l != "-"+line {
+				t.Fatalf("expected intact line in %s, got %d bytes", name, len(l))
+			}
+			lines++
+		}
+	}
+	if lines != 100 {
+		t.Errorf("expected 100 lines, got %d", lines)
+	}
+}
+
+// Compares FileWriter modes with the default one, which opens and closes the file for every record:
+//
+//	default                  4 200 ns/op
+//	keep open                2 400 ns/op
+//	keep open sync interval  2 700 ns/op
+//	keep open sync always   75 000 ns/op
+//	sync always             73 000 ns/op
+func BenchmarkFileWriter(b *testing.B) {
+	modes := []struct {
+		name string
+		w    *FileWriter
+	}{
+		{"default", &FileWriter{}},
+		{"keep open", &FileWriter{KeepOpen: true}},
+		{"keep open sync interval", &FileWriter{KeepOpen: true, Sync: SyncInterval}},
+		{"keep open sync always", &FileWriter{KeepOpen: true, Sync: SyncAlways}},
+		{"sync always", &FileWriter{Sync: SyncAlways}},
+	}
+	line := []byte(`{"msg":"test"}` + "\n")
+	for _, m := range modes {
+		b.Run(m.name, func(b *testing.B) {
+			m.w.Dir = b.TempDir()
+			defer m.w.Close()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.w.Write(line); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 // 15 000 ns/op
 func Benchmark_appendToFile(b *testing.B) {
 	pathname := path.Join(b.TempDir(), "test.log")
 	for i := 0; i < b.N; i++ {
-		if err := appendToFile(pathname, []byte("test")); err != nil {
+		if err := appendToFile(pathname, []byte("test"), false); err != nil {
 			b.Fatal(err)
 		}
 	}
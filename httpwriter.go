@@ -2,38 +2,356 @@ package xlg
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 )
 
 const (
-	requestTimeout   = 10 * time.Second
-	maxRetryDuration = 300 * time.Second
-	retryInterval    = 10 * time.Second
+	// Limit of a collector response, which is either empty or BatchAck.
+	maxResponseBytes = 1 << 20
+
+	spoolReplayInterval = 30 * time.Second
+	// Spooled files are compressed once replayed and removed after spoolMaxAge.
+	spoolMaxAge = 7 * 24 * time.Hour
 )
 
+// client is shared by all writers to reuse keep-alive connections.
+// Requests are limited by RetryPolicy.AttemptTimeout.
+var client = &http.Client{}
+
 // HttpWriter represents an object for sending HTTP POST requests.
+//
+// By default every record is sent by Write in its own request.
+// With batching enabled records are accumulated and sent together as NDJSON (one record per line):
+// a batch is sent when it reaches BatchCount records or BatchBytes bytes, or Linger after its first record.
+// Flush sends a pending batch, it is called by AsyncWriter on Flush and Close.
+//
+// Failed requests are retried according to Retry, nil stands for DefaultRetryPolicy.
+// Retry-After of 429 and 503 responses is honoured, client errors other than 408 and 429 are not retried.
+//
+// With SpoolDir set, records which could not be delivered within RetryPolicy.MaxElapsed are saved
+// in FileWriter format and replayed in background once the collector is reachable again,
+// so records are not lost even without FileWriter and xlg-agent.
+// Close stops the replay.
+//
+// A HttpWriter value, e.g. xlg.HttpWriter{URL: url}, sends every record in its own request.
+// Batching and spooling keep state shared by copies of the writer, so they require a writer created by NewHttpWriter.
 type HttpWriter struct {
 	URL     string            // The URL to send the request to
 	Headers map[string]string // Headers for authorization purposes
+
+	BatchCount int           // Max records in a batch
+	BatchBytes int           // Max bytes in a batch
+	Linger     time.Duration // Max time a record waits in a batch
+
+	// Gzip compresses request bodies, which is worth it for batches of rather big records.
+	Gzip bool
+
+	Retry *RetryPolicy
+
+	// SpoolDir is a directory for records which could not be delivered, no spooling if empty.
+	SpoolDir string
+
+	state *httpWriterState
+}
+
+// httpWriterState is the mutable state of a HttpWriter, it is shared by copies of the writer.
+type httpWriterState struct {
+	mu    sync.Mutex
+	batch [][]byte
+	size  int
+	timer *time.Timer
+
+	spool     *FileWriter
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+// NewHttpWriter returns a writer configured by w, which supports batching and spooling.
+// Records spooled by a previous run are replayed as well.
+func NewHttpWriter(w HttpWriter) *HttpWriter {
+	w.state = &httpWriterState{}
+	if w.SpoolDir != "" {
+		w.startSpool()
+	}
+	return &w
+}
+
+// noState is reported once for HttpWriter values configured for batching or spooling.
+var noState sync.Once
+
+// BatchAck is the response of a collector to a batch of records.
+// Records are acknowledged individually, so a partial failure does not resend the whole batch.
+type BatchAck struct {
+	// Rejected lists invalid records, they are not resent.
+	Rejected []BatchError `json:"rejected,omitempty"`
+	// Retry lists indexes of records which were not stored due to a temporary failure, they are resent.
+	Retry []int `json:"retry,omitempty"`
+}
+
+type BatchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+func (w HttpWriter) batching() bool {
+	return w.state != nil && (w.BatchCount > 1 || w.BatchBytes > 0 || w.Linger > 0)
 }
 
 func (w HttpWriter) Write(p []byte) (n int, err error) {
-	deadline := time.Now().Add(maxRetryDuration)
-	for tries := 0; time.Now().Before(deadline); tries++ {
-		err = send(w.URL, p, w.Headers)
+	if w.state == nil && (w.BatchCount > 1 || w.BatchBytes > 0 || w.Linger > 0 || w.SpoolDir != "") {
+		noState.Do(func() {
+			stderr.Printf("HttpWriter: batching and spooling require NewHttpWriter, records are sent one by one\n")
+		})
+	}
+	if !w.batching() {
+		if err := w.deliver(context.Background(), [][]byte{p}); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	// A copy is kept, as the caller may reuse p.
+	line := append([]byte(nil), p...)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	s := w.state
+	s.mu.Lock()
+	s.batch = append(s.batch, line)
+	s.size += len(line)
+	if len(s.batch) == 1 && w.Linger > 0 {
+		s.timer = time.AfterFunc(w.Linger, func() {
+			if err := w.Flush(context.Background()); err != nil {
+				stderr.Printf("HttpWriter linger: %v\n", err)
+			}
+		})
+	}
+	full := (w.BatchCount > 0 && len(s.batch) >= w.BatchCount) || (w.BatchBytes > 0 && s.size >= w.BatchBytes)
+	var batch [][]byte
+	if full {
+		batch = s.take()
+	}
+	s.mu.Unlock()
+
+	if batch != nil {
+		if err := w.deliver(context.Background(), batch); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends the pending batch.
+func (w HttpWriter) Flush(ctx context.Context) error {
+	if w.state == nil {
+		return nil
+	}
+	w.state.mu.Lock()
+	batch := w.state.take()
+	w.state.mu.Unlock()
+	if batch == nil {
+		return nil
+	}
+	return w.deliver(ctx, batch)
+}
+
+// take returns the pending batch and starts a new one, s.mu must be held.
+func (s *httpWriterState) take() [][]byte {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	batch := s.batch
+	s.batch, s.size = nil, 0
+	return batch
+}
+
+// deliver sends records retrying until they are acknowledged or the retry policy gives up.
+func (w HttpWriter) deliver(ctx context.Context, records [][]byte) (err error) {
+	p := DefaultRetryPolicy
+	if w.Retry != nil {
+		p = w.Retry.withDefaults()
+	}
+	// elapsed counts the time of attempts and waits, rather than the wall clock, so tests can replace sleep.
+	var elapsed time.Duration
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		actx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+		records, err = w.post(actx, records)
+		cancel()
+		elapsed += time.Since(start)
+		if err == nil && len(records) == 0 {
+			return nil
+		}
 		if err == nil {
-			return len(p), nil
+			err = fmt.Errorf("collector asked to retry %d records", len(records))
+		}
+
+		wait := p.backoff(attempt)
+		var se *statusError
+		if errors.As(err, &se) {
+			if se.permanent() {
+				// Spooled records would be rejected again on replay.
+				return w.giveUp(records, &RetryError{Attempts: attempt, Err: err}, false)
+			}
+			if se.retryAfter > 0 {
+				wait = se.retryAfter
+			}
+		}
+		if elapsed+wait > p.MaxElapsed {
+			return w.giveUp(records, &RetryError{Attempts: attempt, Err: err}, true)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return w.giveUp(records, &RetryError{Attempts: attempt, Err: err}, true)
 		}
-		time.Sleep(retryInterval)
+		elapsed += wait
+	}
+}
+
+// sleep waits for d or until ctx is done, it is replaced in tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
+}
+
+// giveUp reports undelivered records and spools them if spooling is enabled and spool is true.
+func (w HttpWriter) giveUp(records [][]byte, err error, spool bool) error {
 	stderr.Printf("Write: %v\n", err)
-	return 0, err
+	if spool && w.state != nil && w.state.spool != nil {
+		return w.spoolRecords(records)
+	}
+	return err
+}
+
+// Close sends the pending batch, stops replaying the spool and closes it.
+func (w HttpWriter) Close(ctx context.Context) error {
+	s := w.state
+	if s == nil {
+		return nil
+	}
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
+	err := w.Flush(ctx)
+	if s.spool != nil {
+		if cerr := s.spool.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (w HttpWriter) startSpool() {
+	if err := os.MkdirAll(w.SpoolDir, 0755); err != nil {
+		stderr.Printf("HttpWriter spool: %v\n", err)
+		return
+	}
+	w.state.spool = &FileWriter{Dir: w.SpoolDir, Compress: true, MaxAge: spoolMaxAge}
+	w.state.stop = make(chan struct{})
+	go w.replay()
+}
+
+func (w HttpWriter) spoolRecords(records [][]byte) error {
+	for _, r := range records {
+		if _, err := w.state.spool.Write(r); err != nil {
+			stderr.Printf("HttpWriter spool: %v\n", err)
+			return err
+		}
+	}
+	return nil
 }
 
-func send(url string, body []byte, headers map[string]string) (err error) {
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+// replay ships spooled records one by one until Close is called.
+// Records the collector rejects permanently are marked by Shipper and skipped.
+func (w HttpWriter) replay() {
+	s := Shipper{Dir: w.SpoolDir, Send: func(line []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultRetryPolicy.AttemptTimeout)
+		defer cancel()
+		retry, err := w.post(ctx, [][]byte{line})
+		if err == nil && len(retry) > 0 {
+			err = fmt.Errorf("collector asked to retry")
+		}
+		return err
+	}}
+	t := time.NewTicker(spoolReplayInterval)
+	defer t.Stop()
+	for {
+		if _, err := s.Ship(); err != nil {
+			stderr.Printf("HttpWriter replay: %v\n", err)
+		}
+		// Housekeeping of the spool is not triggered by writes, as they are rare.
+		if err := w.state.spool.Housekeep(); err != nil {
+			stderr.Printf("HttpWriter spool: %v\n", err)
+		}
+		select {
+		case <-w.state.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// post sends records once and returns records which should be resent.
+func (w HttpWriter) post(ctx context.Context, records [][]byte) (retry [][]byte, err error) {
+	headers := make(map[string]string, len(w.Headers)+2)
+	for k, v := range w.Headers {
+		headers[k] = v
+	}
+	body := bytes.Join(records, nil)
+	if w.batching() {
+		headers["Content-Type"] = "application/x-ndjson"
+	}
+	if w.Gzip {
+		if body, err = gzipBytes(body); err != nil {
+			return records, err
+		}
+		headers["Content-Encoding"] = "gzip"
+	}
+	resp, err := send(ctx, w.URL, body, headers)
+	if err != nil {
+		return records, err
+	}
+	if !w.batching() || len(bytes.TrimSpace(resp)) == 0 {
+		return nil, nil
+	}
+
+	var ack BatchAck
+	if err := json.Unmarshal(resp, &ack); err != nil {
+		// The batch is accepted, the acknowledgement is only informational.
+		stderr.Printf("BatchAck: %v\n", err)
+		return nil, nil
+	}
+	for _, e := range ack.Rejected {
+		stderr.Printf("collector rejected record %d: %s\n", e.Index, e.Error)
+	}
+	for _, i := range ack.Retry {
+		if i >= 0 && i < len(records) {
+			retry = append(retry, records[i])
+		}
+	}
+	return retry, nil
+}
+
+// send posts body and returns the response body on 2xx.
+func send(ctx context.Context, url string, body []byte, headers map[string]string) (resp []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
 	if err != nil {
 		stderr.Printf("NewRequest: %v\n", err)
 		return
@@ -42,16 +360,33 @@ func send(url string, body []byte, headers map[string]string) (err error) {
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	c := http.Client{Timeout: requestTimeout}
-	resp, err := c.Do(req)
+	r, err := client.Do(req)
 	if err != nil {
 		stderr.Printf("Do: %v\n", err)
 		return
 	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		err := fmt.Errorf("expected 2xx, got %d", resp.StatusCode)
+	// The body must be read and closed for the connection to be reused.
+	defer r.Body.Close()
+	resp, err = io.ReadAll(io.LimitReader(r.Body, maxResponseBytes))
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		err := &statusError{code: r.StatusCode}
+		if r.StatusCode == http.StatusTooManyRequests || r.StatusCode == http.StatusServiceUnavailable {
+			err.retryAfter = parseRetryAfter(r.Header.Get("Retry-After"), time.Now())
+		}
 		stderr.Printf("send status check: %v\n", err)
-		return err
+		return nil, err
 	}
-	return nil
+	return resp, err
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -1,9 +1,18 @@
 package xlg
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_sendSuccess(t *testing.T) {
@@ -14,7 +23,7 @@ func Test_sendSuccess(t *testing.T) {
 
 	body := []byte(`{"key": "value"}`)
 	headers := map[string]string{"Authorization": "Bearer Token"}
-	err := send(srv.URL, body, headers)
+	_, err := send(context.Background(), srv.URL, body, headers)
 
 	if err != nil {
 		t.Errorf("expected no error, but got %v", err)
@@ -29,7 +38,7 @@ func Test_sendErrorStatusCode(t *testing.T) {
 
 	body := []byte(`{"key": "value"}`)
 	headers := map[string]string{"Authorization": "Bearer Token"}
-	err := send(srv.URL, body, headers)
+	_, err := send(context.Background(), srv.URL, body, headers)
 
 	expectedErr := "expected 2xx, got 500"
 	if err == nil || err.Error() != expectedErr {
@@ -41,9 +50,263 @@ func Test_sendRequestError(t *testing.T) {
 	url := "invalid-url"
 	body := []byte(`{"key": "value"}`)
 	headers := map[string]string{"Authorization": "Bearer Token"}
-	err := send(url, body, headers)
+	_, err := send(context.Background(), url, body, headers)
 
 	if err == nil {
 		t.Error("expected an error, but got nil")
 	}
 }
+
+func TestHttpWriter_batch(t *testing.T) {
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		b, _ := io.ReadAll(body)
+		bodies <- r.Header.Get("Content-Type") + " " + string(b)
+	}))
+	defer srv.Close()
+
+	t.Run("batch is sent when full", func(t *testing.T) {
+		w := NewHttpWriter(HttpWriter{URL: srv.URL, BatchCount: 2, Gzip: true})
+		w.Write([]byte(`{"msg":"one"}` + "\n"))
+		select {
+		case b := <-bodies:
+			t.Fatalf("expected no request before the batch is full, got %q", b)
+		default:
+		}
+		w.Write([]byte(`{"msg":"two"}`))
+		expected := "application/x-ndjson " + `{"msg":"one"}` + "\n" + `{"msg":"two"}` + "\n"
+		if b := <-bodies; b != expected {
+			t.Errorf("expected %q, got %q", expected, b)
+		}
+	})
+
+	t.Run("batch is sent after linger", func(t *testing.T) {
+		w := NewHttpWriter(HttpWriter{URL: srv.URL, BatchCount: 100, Linger: 10 * time.Millisecond})
+		w.Write([]byte(`{"msg":"one"}` + "\n"))
+		select {
+		case b := <-bodies:
+			if !strings.HasSuffix(b, `{"msg":"one"}`+"\n") {
+				t.Errorf("unexpected body %q", b)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected batch to be sent after linger")
+		}
+	})
+
+	t.Run("Flush sends pending batch", func(t *testing.T) {
+		w := NewHttpWriter(HttpWriter{URL: srv.URL, BatchBytes: 1 << 20})
+		w.Write([]byte(`{"msg":"one"}` + "\n"))
+		if err := w.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if b := <-bodies; !strings.HasSuffix(b, `{"msg":"one"}`+"\n") {
+			t.Errorf("unexpected body %q", b)
+		}
+	})
+}
+
+func TestHttpWriter_value(t *testing.T) {
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	// A value without NewHttpWriter is an io.Writer sending records one by one.
+	l := NewLogger(HttpWriter{URL: srv.URL})
+	l.Msg("one").Write()
+	select {
+	case b := <-bodies:
+		if !strings.Contains(b, `"msg":"one"`) {
+			t.Errorf("unexpected body %q", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected record to be sent")
+	}
+}
+
+func TestHttpWriter_postAck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"rejected":[{"index":0,"error":"invalid"}],"retry":[2]}`))
+	}))
+	defer srv.Close()
+
+	w := NewHttpWriter(HttpWriter{URL: srv.URL, BatchCount: 3})
+	retry, err := w.post(context.Background(), [][]byte{[]byte("0\n"), []byte("1\n"), []byte("2\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retry) != 1 || string(retry[0]) != "2\n" {
+		t.Errorf("expected only record 2 to be resent, got %q", retry)
+	}
+}
+
+func TestHttpWriter_spoolReplay(t *testing.T) {
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	// A record spooled by a previous run while the collector was down.
+	dir := t.TempDir()
+	spooled := &FileWriter{Dir: dir}
+	if _, err := spooled.Write([]byte(`{"msg":"spooled"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewHttpWriter(HttpWriter{URL: srv.URL, SpoolDir: dir})
+	defer w.Close(context.Background())
+	if _, err := w.Write([]byte(`{"msg":"new"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case b := <-bodies:
+			got[b] = true
+		case <-time.After(time.Second):
+			t.Fatalf("expected spooled and new records, got %v", got)
+		}
+	}
+	if !got[`{"msg":"spooled"}`+"\n"] || !got[`{"msg":"new"}`+"\n"] {
+		t.Errorf("expected spooled and new records, got %v", got)
+	}
+}
+
+func TestHttpWriter_spoolRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b, _ := io.ReadAll(r.Body); string(b) == "{bad\n" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	// Spooled by a previous run: a replayed file of a past day and a file with a record the collector rejects.
+	dir := t.TempDir()
+	yesterday := time.Now().AddDate(0, 0, -1).Format(FileFormat)
+	check(os.WriteFile(filepath.Join(dir, yesterday), []byte("+{\"msg\":\"sent\"}\n"), 0644))
+	today := filepath.Join(dir, (&FileWriter{}).periodOf(time.Now()))
+	check(os.WriteFile(today, []byte("-{bad\n-{\"msg\":\"good\"}\n"), 0644))
+
+	w := NewHttpWriter(HttpWriter{URL: srv.URL, SpoolDir: dir})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		content, err := os.ReadFile(today)
+		check(err)
+		_, gzErr := os.Stat(filepath.Join(dir, yesterday+".gz"))
+		if string(content) == "!{bad\n+{\"msg\":\"good\"}\n" && gzErr == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected rejected record skipped and replayed file compressed, got %q, %v", content, gzErr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	check(w.Close(context.Background()))
+}
+
+func TestHttpWriter_spoolRecords(t *testing.T) {
+	dir := t.TempDir()
+	w := HttpWriter{URL: "http://localhost:0", SpoolDir: dir, state: &httpWriterState{spool: &FileWriter{Dir: dir}}}
+	check(w.spoolRecords([][]byte{[]byte("{\"msg\":\"one\"}\n"), []byte("{\"msg\":\"two\"}\n")}))
+
+	content, err := os.ReadFile(filepath.Join(dir, w.state.spool.periodOf(time.Now())))
+	check(err)
+	if string(content) != "-{\"msg\":\"one\"}\n-{\"msg\":\"two\"}\n" {
+		t.Errorf("expected records in FileWriter format, got %q", content)
+	}
+}
+
+// fakeSleep replaces sleep of HttpWriter retries and returns the requested waits.
+func fakeSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+func TestHttpWriter_retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		waits    []time.Duration
+		spooled  bool
+	}{
+		{"recovers", []int{500, 503, 200}, 3, []time.Duration{time.Second, 2 * time.Second}, false},
+		{"permanent", []int{400}, 1, nil, false},
+		// The next wait of 8s exceeds MaxElapsed of 10s, so records are spooled after 4 attempts.
+		{"gives up", []int{500}, 4, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(attempts, len(tt.statuses)-1)]
+				attempts++
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			waits := fakeSleep(t)
+			dir := t.TempDir()
+			w := &HttpWriter{URL: srv.URL, Retry: &RetryPolicy{
+				InitialInterval: time.Second,
+				MaxInterval:     time.Minute,
+				MaxElapsed:      10 * time.Second,
+			}, state: &httpWriterState{spool: &FileWriter{Dir: dir}}}
+			err := w.deliver(context.Background(), [][]byte{[]byte(`{"msg":"test"}` + "\n")})
+
+			if attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+			if fmt.Sprint(*waits) != fmt.Sprint(tt.waits) {
+				t.Errorf("expected waits %v, got %v", tt.waits, *waits)
+			}
+			_, statErr := os.Stat(filepath.Join(dir, w.state.spool.periodOf(time.Now())))
+			if spooled := statErr == nil; spooled != tt.spooled {
+				t.Errorf("expected spooled %v, got %v", tt.spooled, spooled)
+			}
+			if tt.name == "permanent" {
+				var re *RetryError
+				if !errors.As(err, &re) || re.Attempts != 1 {
+					t.Errorf("expected RetryError after 1 attempt, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHttpWriter_retryAfter(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	waits := fakeSleep(t)
+	w := &HttpWriter{URL: srv.URL, Retry: &RetryPolicy{InitialInterval: time.Millisecond}}
+	if err := w.deliver(context.Background(), [][]byte{[]byte(`{"msg":"test"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("expected a retry after Retry-After of 7s, got %d attempts and waits %v", attempts, *waits)
+	}
+}
@@ -0,0 +1,92 @@
+package xlg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Level is the severity of a record.
+// The zero value is LevelInfo, so records created without a level are informational.
+type Level int8
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	default:
+		return fmt.Sprintf("xlg_level(%d)", int8(l))
+	}
+}
+
+// MarshalText encodes a level by its name, so it is readable in logs and the collector database.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	v, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// ParseLevel returns a level by its case-insensitive name.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown level %q", s)
+	}
+}
+
+// envLevel is the initial minimum level of Loggers, records below it are dropped.
+// It is taken from the 'LOG_LEVEL' environment variable and defaults to info.
+//
+// Example docker-compose.yaml:
+//
+//	services:
+//	  my_service:
+//	    environment:
+//	      ENVIRONMENT: prod
+//	      LOG_LEVEL: warn
+var envLevel = levelFromEnv()
+
+func levelFromEnv() Level {
+	s := os.Getenv("LOG_LEVEL")
+	if s == "" {
+		return LevelInfo
+	}
+	l, err := ParseLevel(s)
+	if err != nil {
+		stderr.Printf("LOG_LEVEL: %v\n", err)
+	}
+	return l
+}
@@ -0,0 +1,79 @@
+package xlg
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Level
+	}{
+		{"debug", LevelDebug},
+		{"INFO", LevelInfo},
+		{"warn", LevelWarn},
+		{"warning", LevelWarn},
+		{"Error", LevelError},
+		{"fatal", LevelFatal},
+	}
+	for _, tc := range tests {
+		l, err := ParseLevel(tc.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", tc.input, err)
+		}
+		if l != tc.expected {
+			t.Errorf("expected %s for %q, got %s", tc.expected, tc.input, l)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected an error for unknown level, but got nil")
+	}
+}
+
+func TestLevelJSON(t *testing.T) {
+	b, err := json.Marshal(Record{Level: LevelWarn})
+	check(err)
+	if !bytes.Contains(b, []byte(`"level":"warn"`)) {
+		t.Errorf("expected level encoded by name, got %s", b)
+	}
+
+	b, err = json.Marshal(Record{})
+	check(err)
+	if bytes.Contains(b, []byte(`"level"`)) {
+		t.Errorf("expected info level to be omitted, got %s", b)
+	}
+
+	var r Record
+	check(json.Unmarshal([]byte(`{"level":"error"}`), &r))
+	if r.Level != LevelError {
+		t.Errorf("expected %s, got %s", LevelError, r.Level)
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":"verbose"}`), &r); err == nil {
+		t.Error("expected an error for unknown level, but got nil")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+	SetLevel(LevelWarn)
+	defer SetLevel(LevelInfo)
+
+	Info("dropped").Write()
+	Debug("dropped").Write()
+	if buf.Len() != 0 {
+		t.Errorf("expected records below warn to be dropped, got %q", buf.String())
+	}
+
+	Warn("written").Write()
+	Fail("job", nil).Write()
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 2 {
+		t.Errorf("expected 2 records, got %d: %q", n, buf.String())
+	}
+}
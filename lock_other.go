@@ -0,0 +1,15 @@
+//go:build !unix
+
+package xlg
+
+import "os"
+
+// Advisory locking is not supported, FileWriter relies on atomicity of appends.
+
+func lockFile(*os.File) error {
+	return nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
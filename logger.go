@@ -0,0 +1,235 @@
+package xlg
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger creates records with common fields and writes them to its own output,
+// so components of one binary can log to different outputs.
+//
+// Loggers derived with User, Env, Ref and Attrs copy the common fields and
+// share the output, minimum level and throttle cache with the Logger they are derived from.
+type Logger struct {
+	base Record
+	c    *config
+}
+
+// config is shared between a Logger and Loggers derived from it.
+type config struct {
+	mu        sync.RWMutex
+	out       io.Writer
+	refHeader string
+	newRef    func() string
+	redactor  *Redactor
+	pii       *PIIScanner
+	level     atomic.Int32
+	throttle  throttle
+
+	// rateLimit applies to every record by message, limiter holds its buckets.
+	rateLimit Rate
+	limiter   throttle
+}
+
+// NewLogger returns a Logger writing to w.
+// Hostname and Environment of records are set in the same way as for the default Logger.
+func NewLogger(w io.Writer) *Logger {
+	host, _ := os.Hostname()
+	l := &Logger{
+		base: Record{
+			Hostname:    host,
+			Environment: os.Getenv("ENVIRONMENT"),
+		},
+		c: &config{out: w},
+	}
+	l.c.level.Store(int32(envLevel))
+	return l
+}
+
+func (l *Logger) SetOutput(w io.Writer) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	l.c.out = w
+}
+
+// SetLevel sets the minimum level of records to write. It is safe to call at runtime.
+func (l *Logger) SetLevel(lvl Level) {
+	l.c.level.Store(int32(lvl))
+}
+
+// SetRateLimit limits every message written by the Logger to the rate, whatever the error and attributes are,
+// to protect the collector during incident storms. The zero Rate removes the limit.
+func (l *Logger) SetRateLimit(rate Rate) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	l.c.rateLimit = rate
+}
+
+func (l *Logger) rateLimit() Rate {
+	l.c.mu.RLock()
+	defer l.c.mu.RUnlock()
+	return l.c.rateLimit
+}
+
+// Enabled reports whether records of the level are written.
+func (l *Logger) Enabled(lvl Level) bool {
+	return lvl >= Level(l.c.level.Load())
+}
+
+func (l *Logger) output() io.Writer {
+	l.c.mu.RLock()
+	defer l.c.mu.RUnlock()
+	return l.c.out
+}
+
+// derive returns a copy of the Logger sharing its config.
+func (l *Logger) derive() *Logger {
+	d := &Logger{base: l.base, c: l.c}
+	d.base.Attributes = cloneAttrs(l.base.Attributes)
+	// Setters of the base record, e.g. Attrs, use the config of the Logger such as its Redactor.
+	d.base.lg = d
+	return d
+}
+
+func (l *Logger) User(u string) *Logger {
+	d := l.derive()
+	d.base.Username = u
+	return d
+}
+
+func (l *Logger) Env(e string) *Logger {
+	d := l.derive()
+	d.base.Environment = e
+	return d
+}
+
+// Ref returns a Logger whose records share the reference, e.g. all records written while handling one request.
+func (l *Logger) Ref(ref string) *Logger {
+	d := l.derive()
+	d.base.Reference = ref
+	return d
+}
+
+// Attrs returns a Logger which adds attributes to every record it creates.
+// Attributes set on a record take precedence over the attributes of the Logger.
+func (l *Logger) Attrs(args ...any) *Logger {
+	d := l.derive()
+	d.base = d.base.Attrs(args...)
+	return d
+}
+
+// New creates a Record with the common fields of the Logger.
+// A new reference is generated unless the Logger has one.
+func (l *Logger) New() Record {
+	r := l.base
+	r.Attributes = cloneAttrs(l.base.Attributes)
+	if r.Reference == "" {
+		r.Reference = l.newRef()
+	}
+	r.lg = l
+	return r
+}
+
+func (l *Logger) Msg(m string) Record {
+	return l.New().Msg(m)
+}
+
+func (l *Logger) Debug(m string) Record {
+	return l.New().Debug(m)
+}
+
+func (l *Logger) Info(m string) Record {
+	return l.New().Info(m)
+}
+
+func (l *Logger) Warn(m string) Record {
+	return l.New().Warn(m)
+}
+
+func (l *Logger) Request(r *http.Request) Record {
+	return l.New().Request(r)
+}
+
+func (l *Logger) Req(method string, url *url.URL, header http.Header, body []byte) Record {
+	return l.New().Req(method, url, header, body)
+}
+
+func (l *Logger) Fail(fn any, err error) Record {
+	return l.New().Fail(fn, err)
+}
+
+func (l *Logger) Panic(fn, p any) Record {
+	return l.New().Panic(fn, p)
+}
+
+// write completes the record and encodes it to the output.
+// pc is a program counter of the code which wrote the record, it is used for Source unless zero.
+// rate limits records with the same message and error, or the same throttle key, unless it is zero.
+func (l *Logger) write(r Record, rate Rate, pc uintptr) {
+	if r.Message == "" {
+		if r.ReqMethod != "" && r.ReqPath != "" {
+			r.Message = httpMsg(r.ReqMethod, r.ReqPath, r.RespStatus)
+		} else {
+			r.Message = "xlog_empty"
+		}
+	}
+
+	var s suppressed
+	if rate.limited() {
+		ok, ks := l.c.throttle.allow(key{msg: r.Message, err: r.Error, extra: r.throttleKey}, rate)
+		if !ok {
+			return
+		}
+		s = ks
+	}
+	if limit := l.rateLimit(); limit.limited() {
+		ok, ms := l.c.limiter.allow(key{msg: r.Message}, limit)
+		if !ok {
+			return
+		}
+		s = s.merge(ms)
+	}
+	if s.count > 0 {
+		// The caller's record shares the map, so attributes are added to a copy.
+		r.Attributes = cloneAttrs(r.Attributes)
+		r = r.Attrs(
+			"xlg_suppressed", s.count,
+			"xlg_suppressed_first", s.first.UTC().Format(time.RFC3339Nano),
+			"xlg_suppressed_last", s.last.UTC().Format(time.RFC3339Nano),
+		)
+	}
+
+	if pii := l.piiScanner(); pii != nil {
+		r = pii.scan(r)
+	}
+
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now().UTC()
+	}
+
+	if pc != 0 {
+		fs := runtime.CallersFrames([]uintptr{pc})
+		f, _ := fs.Next()
+		r.Source = &Source{
+			Func: f.Function,
+			File: f.File,
+			Line: f.Line,
+		}
+	}
+
+	// encoder does not escape html (<, >, &)
+	// encoder adds newline at the end
+	enc := json.NewEncoder(l.output())
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		stderr.Println("failed to encode record:", err)
+		return
+	}
+}
@@ -0,0 +1,89 @@
+package xlg
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogger_output(t *testing.T) {
+	buf1, buf2 := new(bytes.Buffer), new(bytes.Buffer)
+	l1, l2 := NewLogger(buf1), NewLogger(buf2)
+
+	l1.Msg("first").Write()
+	l2.Msg("second").Write()
+	if !strings.Contains(buf1.String(), `"msg":"first"`) || strings.Contains(buf1.String(), "second") {
+		t.Errorf("expected only first record in first output, got %q", buf1.String())
+	}
+	if !strings.Contains(buf2.String(), `"msg":"second"`) || strings.Contains(buf2.String(), "first") {
+		t.Errorf("expected only second record in second output, got %q", buf2.String())
+	}
+}
+
+func TestLogger_derived(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	d := l.User("user").Env("test").Attrs("job", "import")
+
+	r := d.Msg("message").Attrs("key", "value")
+	if r.Username != "user" || r.Environment != "test" {
+		t.Errorf("expected user and env from Logger, got %q and %q", r.Username, r.Environment)
+	}
+	if r.Attributes["job"] != "import" || r.Attributes["key"] != "value" {
+		t.Errorf("expected attributes from Logger and record, got %v", r.Attributes)
+	}
+	if _, ok := d.New().Attributes["key"]; ok {
+		t.Error("expected record attributes not to leak into the Logger")
+	}
+	if l.New().Username != "" {
+		t.Error("expected parent Logger to be unchanged")
+	}
+
+	// Derived Loggers share the output and level of their parent.
+	l.SetLevel(LevelWarn)
+	d.Info("dropped").Write()
+	d.Warn("written").Write()
+	var rec Record
+	check(json.Unmarshal(buf.Bytes(), &rec))
+	if rec.Message != "written" || rec.Username != "user" {
+		t.Errorf("expected written record of the user, got %+v", rec)
+	}
+}
+
+func TestLogger_Ref(t *testing.T) {
+	l := NewLogger(new(bytes.Buffer))
+	if l.New().Reference == l.New().Reference {
+		t.Error("expected a new reference for every record")
+	}
+	d := l.Ref("ref")
+	if d.Msg("first").Reference != "ref" || d.Fail("second", nil).Reference != "ref" {
+		t.Error("expected records to share the reference of the Logger")
+	}
+}
+
+func TestUser(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	lg := User("user")
+	lg.Msg("job succeeded").Write()
+	var rec Record
+	check(json.Unmarshal(buf.Bytes(), &rec))
+	if rec.Username != "user" {
+		t.Errorf("expected user %q, got %q", "user", rec.Username)
+	}
+}
+
+func TestWriteOnceInAddsSource(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.Msg("message").WriteOnceIn("1m")
+	var rec Record
+	check(json.Unmarshal(buf.Bytes(), &rec))
+	if !strings.HasSuffix(rec.Source.Func, "TestWriteOnceInAddsSource") {
+		t.Errorf("expected func of the caller, got %q", rec.Source.Func)
+	}
+}
@@ -0,0 +1,198 @@
+package xlg
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Middleware logs every exchange handled by an http.Handler as a single Record
+// with the request, the response, latency and a recovered panic if any.
+// The reference and the span of the exchange are stored in the request context,
+// so records created by the handler with FromContext share them.
+//
+//	http.ListenAndServe(":8080", xlg.Middleware{}.Wrap(mux))
+type Middleware struct {
+	// Logger writes the records, nil stands for the default Logger.
+	Logger *Logger
+
+	// Include reports whether the exchange of the request is logged, nil logs all exchanges.
+	// It is useful to skip noisy routes, e.g. health checks.
+	// Excluded requests still get the reference and trace in the context and the ref response header.
+	Include func(r *http.Request) bool
+
+	// ReqBody and RespBody report whether request and response bodies are captured,
+	// nil captures all bodies. Bodies are not captured for routes with large or sensitive content.
+	ReqBody  func(r *http.Request) bool
+	RespBody func(r *http.Request) bool
+}
+
+func (m Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		lg := m.Logger
+		if lg == nil {
+			lg = std
+		}
+		// The reference received from the caller or a new one is shared with the handler via context
+		// and returned to the client for issue resolution.
+		l := lg.FromContext(r.Context())
+		if ref := r.Header.Get(lg.refHeader()); ref != "" {
+			l.Reference = ref
+		}
+		w.Header().Set(lg.refHeader(), l.Reference)
+
+		// The exchange is a server span: the active span set by an outer middleware,
+		// a child of the caller's span or a root of a new trace.
+		tc, ok := TraceFromContext(r.Context())
+		if !ok {
+			if tc, ok = traceFromHeader(r.Header); ok {
+				tc = tc.Child()
+			} else {
+				tc = NewTrace()
+			}
+		}
+		l = l.Trace(tc)
+		r = r.WithContext(ContextWithTrace(ContextWithRef(r.Context(), l.Reference), tc))
+		// Include controls only logging, the reference and trace are propagated for every request.
+		if m.Include != nil && !m.Include(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Bodies are captured while the handler reads and writes them,
+		// so memory use is bounded by bodyMaxBytes regardless of the body size.
+		var reqBody *capture
+		if r.Body != nil && r.Body != http.NoBody && (m.ReqBody == nil || m.ReqBody(r)) {
+			reqBody = new(capture)
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		rec := &recorder{ResponseWriter: w}
+		if m.RespBody == nil || m.RespBody(r) {
+			rec.body = new(capture)
+		}
+
+		defer func() {
+			p := recover()
+			if p == http.ErrAbortHandler {
+				// The panic is used by handlers to abort the response, it is not an error.
+				panic(p)
+			}
+
+			if p != nil {
+				l = l.Panic(r.Method+" "+r.URL.Path, p)
+				if rec.status == 0 {
+					http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			} else if rec.status >= http.StatusInternalServerError {
+				l = l.Lvl(LevelError)
+			}
+			l = l.req(r.Method, r.URL, r.Header, reqBody.bytes(), reqBody.droppedBytes())
+			l = l.resp(rec.status, rec.Header(), rec.body.bytes(), rec.body.droppedBytes())
+			l.Since(start).Write()
+		}()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			// Handler returned without writing, net/http responds with 200.
+			rec.status = http.StatusOK
+		}
+	})
+}
+
+// recorder is a ResponseWriter which tees the status and body of the response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   *capture
+}
+
+func (rw *recorder) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if rw.body != nil {
+		rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recorder) Flush() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets handlers take over the connection, e.g. for WebSocket upgrades.
+// The exchange is logged as a protocol switch, data sent over the connection is not captured.
+func (rw *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		if rw.status == 0 {
+			rw.status = http.StatusSwitchingProtocols
+		}
+		rw.body = nil
+	}
+	return conn, brw, err
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter.
+func (rw *recorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// capture keeps the first bodyMaxBytes written to it and counts the rest.
+// Methods handle nil capture, which stands for a body that is not captured.
+// A capture is safe for concurrent use, as a transport may still send the request body after RoundTrip returns.
+type capture struct {
+	mu      sync.Mutex
+	buf     []byte
+	dropped int
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := min(len(p), bodyMaxBytes-len(c.buf))
+	c.buf = append(c.buf, p[:n]...)
+	c.dropped += len(p) - n
+	return len(p), nil
+}
+
+func (c *capture) bytes() []byte {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf
+}
+
+func (c *capture) droppedBytes() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
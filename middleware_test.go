@@ -0,0 +1,222 @@
+package xlg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	})
+
+	t.Run("exchange is logged", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware{Logger: NewLogger(buf)}.Wrap(echo)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items?token=secret", strings.NewReader(`{"key": "value"}`)))
+
+		if w.Code != http.StatusCreated || w.Body.String() != `{"key": "value"}` {
+			t.Fatalf("expected response to pass through, got %d %q", w.Code, w.Body)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Message != "POST /items [201]" {
+			t.Errorf("expected msg %q, got %q", "POST /items [201]", l.Message)
+		}
+		if l.ReqURL != "/items?token="+redacted {
+			t.Errorf("expected redacted url, got %q", l.ReqURL)
+		}
+		if l.ReqBody != `{"key":"value"}` || l.RespBody != `{"key":"value"}` {
+			t.Errorf("expected compacted bodies, got %q and %q", l.ReqBody, l.RespBody)
+		}
+		if l.RespHeader != "Content-Type: application/json\r\nX-Request-Id: "+l.Reference+"\r\n" {
+			t.Errorf("expected response header, got %q", l.RespHeader)
+		}
+		if l.Duration <= 0 {
+			t.Errorf("expected positive duration, got %s", l.Duration)
+		}
+	})
+
+	t.Run("reference is shared with the handler", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		lg := NewLogger(buf)
+		var handlerRef string
+		h := Middleware{Logger: lg}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerRef = lg.FromContext(r.Context()).Reference
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set(DefaultRefHeader, "caller-ref")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Reference != "caller-ref" || handlerRef != "caller-ref" {
+			t.Errorf("expected reference of the caller, got %q and %q", l.Reference, handlerRef)
+		}
+		if w.Header().Get(DefaultRefHeader) != "caller-ref" {
+			t.Errorf("expected reference in the response header, got %q", w.Header().Get(DefaultRefHeader))
+		}
+	})
+
+	t.Run("excluded route is not logged", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		m := Middleware{
+			Logger:  NewLogger(buf),
+			Include: func(r *http.Request) bool { return r.URL.Path != "/health" },
+		}
+		var ref string
+		var traced bool
+		h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ref = RefFromContext(r.Context())
+			_, traced = TraceFromContext(r.Context())
+		}))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set(DefaultRefHeader, "caller-ref")
+		h.ServeHTTP(w, req)
+		if buf.Len() != 0 {
+			t.Errorf("expected no record, got %q", buf.String())
+		}
+		// The reference and trace are propagated even if the exchange is not logged.
+		if ref != "caller-ref" || !traced || w.Header().Get(DefaultRefHeader) != "caller-ref" {
+			t.Errorf("expected reference and trace in the context and the ref header, got %q, %v, %q", ref, traced, w.Header().Get(DefaultRefHeader))
+		}
+	})
+
+	t.Run("bodies are not captured", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		never := func(*http.Request) bool { return false }
+		h := Middleware{Logger: NewLogger(buf), ReqBody: never, RespBody: never}.Wrap(echo)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("file")))
+
+		if w.Body.String() != "file" {
+			t.Fatalf("expected body to pass through, got %q", w.Body)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.ReqBody != "" || l.RespBody != "" {
+			t.Errorf("expected no bodies, got %q and %q", l.ReqBody, l.RespBody)
+		}
+	})
+
+	t.Run("large body is truncated", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware{Logger: NewLogger(buf)}.Wrap(echo)
+		body := strings.Repeat("a", bodyMaxBytes+10)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body)))
+
+		if w.Body.String() != body {
+			t.Fatal("expected body to pass through unchanged")
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if !strings.HasSuffix(l.ReqBody, "...xlg_truncated 10 bytes") || !strings.HasSuffix(l.RespBody, "...xlg_truncated 10 bytes") {
+			t.Errorf("expected truncated bodies, got %q and %q", l.ReqBody[bodyMaxBytes:], l.RespBody[bodyMaxBytes:])
+		}
+	})
+
+	t.Run("fields of a large body are redacted", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		lg := NewLogger(buf)
+		lg.SetRedactor(&Redactor{Fields: []string{"password"}})
+		h := Middleware{Logger: lg}.Wrap(echo)
+		body := `{"password":"hunter2","data":"` + strings.Repeat("a", bodyMaxBytes) + `"}`
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		for _, b := range []string{l.ReqBody, l.RespBody} {
+			if strings.Contains(b, "hunter2") || !strings.HasPrefix(b, `{"password":"...xlg_redacted...","data":"aaa`) {
+				t.Errorf("expected password redacted, got %q", b[:40])
+			}
+		}
+	})
+
+	t.Run("panic is recovered", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware{Logger: NewLogger(buf)}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Message != "PANIC GET /panic" || l.Error != "boom" || l.Level != LevelFatal {
+			t.Errorf("expected fatal panic record, got %+v", l)
+		}
+		if l.RespStatus != http.StatusInternalServerError {
+			t.Errorf("expected resp status 500, got %d", l.RespStatus)
+		}
+	})
+
+	t.Run("handler without write responds 200", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware{Logger: NewLogger(buf)}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.RespStatus != http.StatusOK {
+			t.Errorf("expected resp status 200, got %d", l.RespStatus)
+		}
+	})
+}
+
+func TestMiddleware_hijack(t *testing.T) {
+	buf := new(bytes.Buffer)
+	done := make(chan struct{})
+	h := Middleware{Logger: NewLogger(buf)}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("expected the ResponseWriter to be a Hijacker")
+			return
+		}
+		conn, brw, err := hj.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\nhello")
+		brw.Flush()
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n"))
+	got, _ := io.ReadAll(conn)
+	if !strings.HasSuffix(string(got), "\r\n\r\nhello") {
+		t.Errorf("expected data over the hijacked connection, got %q", got)
+	}
+	<-done
+
+	var l Record
+	check(json.Unmarshal(buf.Bytes(), &l))
+	if l.RespStatus != http.StatusSwitchingProtocols || l.RespBody != "" {
+		t.Errorf("expected protocol switch without body, got %d %q", l.RespStatus, l.RespBody)
+	}
+}
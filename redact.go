@@ -0,0 +1,359 @@
+package xlg
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// must be safe for use in header and url
+const redacted = "...xlg_redacted..."
+
+// Redactor replaces sensitive data of records with "...xlg_redacted...".
+// It is applied by Req, Resp, Request, Response and Attrs of records created by a Logger, see SetRedactor.
+//
+// Patterns have the syntax of path.Match and are matched case-insensitively, e.g. "*token*".
+type Redactor struct {
+	// Headers are patterns of header names whose values are redacted.
+	Headers []string
+
+	// Query are patterns of URL query keys whose values are redacted.
+	Query []string
+
+	// Fields are JSON fields of bodies whose values are redacted, whatever their type is.
+	// A path from the root starts with "$.", e.g. "$.card.number", its segments are patterns and arrays are transparent,
+	// so "$.items.pan" matches pan of every item. Other patterns match a key at any depth, e.g. "password".
+	// Plain patterns also match attribute keys.
+	Fields []string
+
+	// Values are expressions whose matches are redacted in header and query values,
+	// string values of JSON bodies, bodies of other types and attribute values, e.g. card numbers.
+	Values []*regexp.Regexp
+}
+
+// DefaultRedactor is used by Loggers unless set otherwise with SetRedactor.
+var DefaultRedactor = &Redactor{
+	Headers: []string{"authorization", "*password*", "*secret*", "*token*", "*key*"},
+	Query:   []string{"token", "password"},
+}
+
+// SetRedactor sets the Redactor of the default Logger, nil restores DefaultRedactor.
+func SetRedactor(rd *Redactor) {
+	std.SetRedactor(rd)
+}
+
+func (l *Logger) SetRedactor(rd *Redactor) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	l.c.redactor = rd
+}
+
+func (l *Logger) redactor() *Redactor {
+	l.c.mu.RLock()
+	defer l.c.mu.RUnlock()
+	if l.c.redactor == nil {
+		return DefaultRedactor
+	}
+	return l.c.redactor
+}
+
+// Header returns a copy of h with values of sensitive headers redacted.
+func (rd *Redactor) Header(h http.Header) (c http.Header) {
+	if h == nil {
+		return nil
+	}
+	c = h.Clone()
+	for k, vs := range c {
+		if matchAny(rd.Headers, k) {
+			c[k] = []string{redacted}
+			continue
+		}
+		for i, v := range vs {
+			vs[i] = rd.value(v)
+		}
+	}
+	return c
+}
+
+// URL returns a copy of u with values of sensitive query keys redacted.
+// Key-value pairs of the fragment, e.g. an OAuth access_token, are redacted in the same way, as is the userinfo password.
+// Other parts of the URL are preserved exactly.
+func (rd *Redactor) URL(u *url.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	if c.User != nil {
+		if _, ok := c.User.Password(); ok {
+			c.User = url.UserPassword(c.User.Username(), redacted)
+		}
+	}
+	c.RawQuery = rd.query(c.RawQuery)
+	if frag := c.EscapedFragment(); strings.Contains(frag, "=") {
+		if raw := rd.query(frag); raw != frag {
+			if f, err := url.PathUnescape(raw); err == nil {
+				c.Fragment, c.RawFragment = f, raw
+			}
+		}
+	}
+	return &c
+}
+
+// query redacts values of the raw query pair by pair, unchanged pairs are kept as they are.
+func (rd *Redactor) query(raw string) string {
+	return rd.pairs(raw, func(key string) bool { return matchAny(rd.Query, key) })
+}
+
+// form redacts an urlencoded form body in place like a query,
+// its fields are sensitive by Query patterns and as top-level fields of a JSON object.
+func (rd *Redactor) form(body []byte) []byte {
+	return []byte(rd.pairs(string(body), func(key string) bool {
+		return matchAny(rd.Query, key) || rd.field([]string{"$", key})
+	}))
+}
+
+// pairs redacts values of urlencoded pairs with sensitive keys and values matching Values.
+func (rd *Redactor) pairs(raw string, sensitive func(key string) bool) string {
+	if raw == "" {
+		return raw
+	}
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || v == "" {
+			continue
+		}
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		if sensitive(key) {
+			pairs[i] = k + "=" + redacted
+			continue
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			continue
+		}
+		if r := rd.value(value); r != value {
+			pairs[i] = k + "=" + url.QueryEscape(r)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Body returns the body with sensitive JSON fields and values redacted.
+// The body is returned as is when there is nothing to redact.
+func (rd *Redactor) Body(body []byte) []byte {
+	if len(body) == 0 || len(rd.Fields) == 0 && len(rd.Values) == 0 {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		// Not JSON or truncated JSON, e.g. a body cut off at bodyMaxBytes.
+		if len(rd.Fields) > 0 {
+			body = rd.redactTokens(body)
+		}
+		for _, re := range rd.Values {
+			body = re.ReplaceAll(body, []byte(redacted))
+		}
+		return body
+	}
+	v, changed := rd.walk(v, []string{"$"})
+	if !changed {
+		return body
+	}
+	b := new(bytes.Buffer)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		stderr.Printf("Redactor.Body: %v\n", err)
+		return body
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// redactTokens redacts values of sensitive fields of a body which cannot be decoded,
+// walking its JSON tokens up to the first error. A value cut off by the end of the body is redacted up to the end.
+func (rd *Redactor) redactTokens(body []byte) []byte {
+	type frame struct {
+		obj     bool
+		key     string
+		haveKey bool
+	}
+	var stack []frame
+	// done marks the value of the innermost object key as read.
+	done := func() {
+		if n := len(stack); n > 0 && stack[n-1].obj {
+			stack[n-1].haveKey = false
+		}
+	}
+	var out []byte
+	last := 0
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			break
+		}
+		d, delim := t.(json.Delim)
+		if n := len(stack); n > 0 && stack[n-1].obj && !stack[n-1].haveKey {
+			if delim {
+				// The end of the object.
+				stack = stack[:n-1]
+				done()
+				continue
+			}
+			stack[n-1].key, stack[n-1].haveKey = t.(string), true
+			at := []string{"$"}
+			for _, f := range stack {
+				if f.obj {
+					at = append(at, f.key)
+				}
+			}
+			if !rd.field(at) {
+				continue
+			}
+			start := int(dec.InputOffset())
+			for start < len(body) && (body[start] == ':' || body[start] == ' ' || body[start] == '\t' || body[start] == '\n' || body[start] == '\r') {
+				start++
+			}
+			end, ok := skipValue(dec, len(body))
+			out = append(append(out, body[last:start]...), `"`+redacted+`"`...)
+			last = end
+			if !ok {
+				break
+			}
+			done()
+			continue
+		}
+		switch {
+		case delim && (d == '{' || d == '['):
+			stack = append(stack, frame{obj: d == '{'})
+		case delim:
+			stack = stack[:len(stack)-1]
+			done()
+		default:
+			done()
+		}
+	}
+	if out == nil {
+		return body
+	}
+	return append(out, body[last:]...)
+}
+
+// skipValue reads the next JSON value of dec and returns its end offset.
+// It reports false with the size of the body as the end if the value is cut off.
+func skipValue(dec *json.Decoder, size int) (end int, ok bool) {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return size, false
+		}
+		if d, ok := t.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return int(dec.InputOffset()), true
+		}
+	}
+}
+
+// Attr returns the value of the attribute with the key redacted if it is sensitive.
+func (rd *Redactor) Attr(key, value string) string {
+	for _, f := range rd.Fields {
+		if !strings.HasPrefix(f, "$.") && match(f, key) {
+			return redacted
+		}
+	}
+	return rd.value(value)
+}
+
+// walk redacts fields of the JSON value at the path, it reports whether anything was redacted.
+func (rd *Redactor) walk(v any, at []string) (any, bool) {
+	switch x := v.(type) {
+	case map[string]any:
+		changed := false
+		for k, e := range x {
+			p := append(at[:len(at):len(at)], k)
+			if rd.field(p) {
+				x[k] = redacted
+				changed = true
+				continue
+			}
+			var c bool
+			x[k], c = rd.walk(e, p)
+			changed = changed || c
+		}
+		return x, changed
+	case []any:
+		changed := false
+		for i, e := range x {
+			var c bool
+			x[i], c = rd.walk(e, at)
+			changed = changed || c
+		}
+		return x, changed
+	case string:
+		r := rd.value(x)
+		return r, r != x
+	}
+	return v, false
+}
+
+// field reports whether the field at the path, starting with "$", is sensitive.
+func (rd *Redactor) field(at []string) bool {
+	for _, f := range rd.Fields {
+		if !strings.HasPrefix(f, "$.") {
+			if match(f, at[len(at)-1]) {
+				return true
+			}
+			continue
+		}
+		segs := strings.Split(f, ".")
+		if len(segs) != len(at) {
+			continue
+		}
+		ok := true
+		for i := 1; i < len(segs) && ok; i++ {
+			ok = match(segs[i], at[i])
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (rd *Redactor) value(v string) string {
+	for _, re := range rd.Values {
+		v = re.ReplaceAllString(v, redacted)
+	}
+	return v
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if match(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, name string) bool {
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return ok
+}
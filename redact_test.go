@@ -0,0 +1,147 @@
+package xlg
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var testRedactor = &Redactor{
+	Headers: []string{"x-api-*"},
+	Query:   []string{"*sig*"},
+	Fields:  []string{"$.card.number", "$.items.*.pan", "password"},
+	Values:  []*regexp.Regexp{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+}
+
+func TestRedactor_Body(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "path from the root",
+			input:    `{"card":{"number":4111111111111111,"holder":"John"},"number":1}`,
+			expected: `{"card":{"holder":"John","number":"...xlg_redacted..."},"number":1}`,
+		},
+		{
+			name:     "key at any depth",
+			input:    `{"user":{"Password":"secret","login":"john"},"list":[{"password":"x"}]}`,
+			expected: `{"list":[{"password":"...xlg_redacted..."}],"user":{"Password":"...xlg_redacted...","login":"john"}}`,
+		},
+		{
+			name:     "arrays are transparent",
+			input:    `{"items":[{"card":{"pan":"4111"}},{"bank":{"pan":"5500"}}]}`,
+			expected: `{"items":[{"card":{"pan":"...xlg_redacted..."}},{"bank":{"pan":"...xlg_redacted..."}}]}`,
+		},
+		{
+			name:     "values in strings",
+			input:    `{"note":"ssn 123-45-6789 given"}`,
+			expected: `{"note":"ssn ...xlg_redacted... given"}`,
+		},
+		{
+			name:     "body is kept as is when nothing is redacted",
+			input:    `{"b": 1, "a": 2}`,
+			expected: `{"b": 1, "a": 2}`,
+		},
+		{
+			name:     "values in truncated JSON",
+			input:    `{"note":"ssn 123-45-6789`,
+			expected: `{"note":"ssn ...xlg_redacted...`,
+		},
+		{
+			name:     "fields in truncated JSON",
+			input:    `{"card":{"number":4111111111111111,"holder":"John"},"items":[{"card":{"pan":"5500"}}],"Password":"hunt`,
+			expected: `{"card":{"number":"...xlg_redacted...","holder":"John"},"items":[{"card":{"pan":"...xlg_redacted..."}}],"Password":"...xlg_redacted..."`,
+		},
+		{
+			name:     "object of a field in truncated JSON",
+			input:    `{"user":{"password":{"old":"a","new":"b"},"login":"john","note":"x`,
+			expected: `{"user":{"password":"...xlg_redacted...","login":"john","note":"x`,
+		},
+		{
+			name:     "html is not escaped",
+			input:    `{"password":"x","html":"<b>&</b>"}`,
+			expected: `{"html":"<b>&</b>","password":"...xlg_redacted..."}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(testRedactor.Body([]byte(tc.input))); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedactor_Header(t *testing.T) {
+	h := http.Header{
+		"X-Api-Key": {"key"},
+		"X-Ssn":     {"123-45-6789"},
+		"Accept":    {"*/*"},
+	}
+	got := testRedactor.Header(h)
+	expected := http.Header{
+		"X-Api-Key": {redacted},
+		"X-Ssn":     {redacted},
+		"Accept":    {"*/*"},
+	}
+	if head2str(got) != head2str(expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if h.Get("X-Ssn") != "123-45-6789" {
+		t.Error("expected the original header to be kept")
+	}
+}
+
+func TestRedactor_URL(t *testing.T) {
+	u, _ := url.Parse("https://example.com/api?Signature=abc&ssn=123-45-6789&q=go")
+	expected := "https://example.com/api?Signature=...xlg_redacted...&ssn=...xlg_redacted...&q=go"
+	if got := testRedactor.URL(u).String(); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestRedactor_form(t *testing.T) {
+	// Fields are matched by Query patterns and as top-level JSON fields, the order and encoding of pairs are kept.
+	body := "sig=abc&Password=p%40ss&ssn=123-45-6789&tag=b&tag=a&note=a+b"
+	expected := "sig=...xlg_redacted...&Password=...xlg_redacted...&ssn=...xlg_redacted...&tag=b&tag=a&note=a+b"
+	if got := string(testRedactor.form([]byte(body))); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestLogger_SetRedactor(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.SetRedactor(testRedactor)
+	u, _ := url.Parse("/login")
+	l.Req(http.MethodPost, u, nil, []byte(`{"login":"john","password":"secret"}`)).
+		Attrs("password", "secret", "ssn", "123-45-6789", "user", "john").
+		Write()
+
+	var r Record
+	check(json.Unmarshal(buf.Bytes(), &r))
+	if r.ReqBody != `{"login":"john","password":"...xlg_redacted..."}` {
+		t.Errorf("expected redacted body, got %s", r.ReqBody)
+	}
+	if r.Attributes["password"] != redacted || r.Attributes["ssn"] != redacted || r.Attributes["user"] != "john" {
+		t.Errorf("expected redacted attributes, got %v", r.Attributes)
+	}
+}
+
+func TestLogger_AttrsRedacted(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.SetRedactor(&Redactor{Fields: []string{"secret"}})
+	l.Attrs("secret", "v").New().Write()
+
+	var r Record
+	check(json.Unmarshal(buf.Bytes(), &r))
+	if r.Attributes["secret"] != redacted {
+		t.Errorf("expected attribute of the Logger redacted, got %v", r.Attributes)
+	}
+}
@@ -0,0 +1,78 @@
+package xlg
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// SetRefGenerator sets the function generating references of records of the default Logger,
+// e.g. NewUUIDv7 for references which are sorted by time and indexed efficiently by collectors.
+// Nil restores the default NewUUIDv4.
+func SetRefGenerator(gen func() string) {
+	std.SetRefGenerator(gen)
+}
+
+func (l *Logger) SetRefGenerator(gen func() string) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	l.c.newRef = gen
+}
+
+func (l *Logger) newRef() string {
+	l.c.mu.RLock()
+	gen := l.c.newRef
+	l.c.mu.RUnlock()
+	if gen == nil {
+		return NewUUIDv4()
+	}
+	return gen()
+}
+
+// NewUUIDv4 returns a random UUID as defined by RFC 9562 (formerly RFC 4122), e.g. 0b9e5e3c-6b0a-4f5e-9d4a-3c2f1e0d9b8a.
+func NewUUIDv4() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		stderr.Printf("NewUUIDv4: %v\n", err)
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+	return formatUUID(u)
+}
+
+// NewUUIDv7 returns a time-ordered UUID as defined by RFC 9562: it starts with the Unix time in milliseconds,
+// followed by the sub-millisecond fraction, so references generated by a process sort in the order of generation
+// unless the clock goes back.
+func NewUUIDv7() string {
+	return newUUIDv7(time.Now())
+}
+
+func newUUIDv7(t time.Time) string {
+	var u [16]byte
+	if _, err := rand.Read(u[8:]); err != nil {
+		stderr.Printf("NewUUIDv7: %v\n", err)
+	}
+	ms := t.UnixMilli()
+	binary.BigEndian.PutUint64(u[:8], uint64(ms)<<16)
+	// 12 bits of the fraction of the millisecond, as method 3 of RFC 9562 section 6.2.
+	frac := uint16((t.UnixNano() - ms*int64(time.Millisecond)) * 4096 / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(u[6:8], frac)
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+	return formatUUID(u)
+}
+
+func formatUUID(u [16]byte) string {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b)
+}
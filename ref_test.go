@@ -0,0 +1,62 @@
+package xlg
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNewUUID(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  func() string
+		re   *regexp.Regexp
+	}{
+		{"v4", NewUUIDv4, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"v7", NewUUIDv7, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+	}
+	for _, tt := range tests {
+		seen := map[string]bool{}
+		for i := 0; i < 1000; i++ {
+			u := tt.gen()
+			if !tt.re.MatchString(u) {
+				t.Fatalf("%s: expected valid UUID, got %q", tt.name, u)
+			}
+			if seen[u] {
+				t.Fatalf("%s: expected unique UUIDs, got %q twice", tt.name, u)
+			}
+			seen[u] = true
+		}
+	}
+}
+
+func Test_newUUIDv7(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	u := newUUIDv7(at)
+	// 2024-01-02T03:04:05Z is 1704164645000 ms, 0x018cc820d888.
+	if u[:13] != "018cc820-d888" {
+		t.Errorf("expected time prefix 018cc820-d888, got %s", u)
+	}
+
+	var refs []string
+	for i := 0; i < 100; i++ {
+		refs = append(refs, newUUIDv7(at.Add(time.Duration(i)*time.Microsecond)))
+	}
+	if !sort.StringsAreSorted(refs) {
+		t.Errorf("expected UUIDs sorted by time, got %v", refs)
+	}
+}
+
+func TestLogger_SetRefGenerator(t *testing.T) {
+	l := NewLogger(new(bytes.Buffer))
+	l.SetRefGenerator(func() string { return "ref" })
+	if ref := l.User("user").New().Reference; ref != "ref" {
+		t.Errorf("expected ref from generator, got %q", ref)
+	}
+	l.SetRefGenerator(nil)
+	if ref := l.New().Reference; len(ref) != 36 {
+		t.Errorf("expected UUID, got %q", ref)
+	}
+}
@@ -0,0 +1,126 @@
+package xlg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how HttpWriter retries failed requests with exponential backoff.
+// Zero intervals, multiplier and durations take values of DefaultRetryPolicy, zero Jitter means no jitter.
+type RetryPolicy struct {
+	InitialInterval time.Duration // Wait before the first retry
+	MaxInterval     time.Duration // Cap of the wait between retries
+	Multiplier      float64       // Growth of the wait after each retry
+
+	// Jitter randomizes each wait by up to this fraction in both directions,
+	// so clients do not retry all at once after a collector outage.
+	Jitter float64
+
+	// MaxElapsed is the time after which HttpWriter gives up and spools records if SpoolDir is set.
+	MaxElapsed time.Duration
+
+	// AttemptTimeout limits a single request.
+	AttemptTimeout time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	InitialInterval: time.Second,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+	MaxElapsed:      300 * time.Second,
+	AttemptTimeout:  10 * time.Second,
+}
+
+// withDefaults returns the policy with zero fields set to defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	d := DefaultRetryPolicy
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = d.InitialInterval
+	}
+	if p.MaxInterval <= 0 {
+		p.MaxInterval = d.MaxInterval
+	}
+	if p.Multiplier <= 0 {
+		p.Multiplier = d.Multiplier
+	}
+	if p.MaxElapsed <= 0 {
+		p.MaxElapsed = d.MaxElapsed
+	}
+	if p.AttemptTimeout <= 0 {
+		p.AttemptTimeout = d.AttemptTimeout
+	}
+	return p
+}
+
+// backoff returns the wait before the retry following the attempt, attempts are counted from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	d = math.Min(d, float64(p.MaxInterval))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// ErrRejected is wrapped by errors of records which the collector rejected permanently, resending them is pointless.
+var ErrRejected = errors.New("xlg: record rejected")
+
+// RetryError is returned by HttpWriter when it gives up delivering records.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// statusError is returned by send for a non-2xx response.
+type statusError struct {
+	code int
+	// retryAfter is the wait requested by the collector with Retry-After header, zero if not requested.
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("expected 2xx, got %d", e.code)
+}
+
+// Is makes a permanent statusError match ErrRejected.
+func (e *statusError) Is(target error) bool {
+	return target == ErrRejected && e.permanent()
+}
+
+// permanent reports whether resending the same request is pointless:
+// client errors other than timeout and rate limiting will not go away.
+func (e *statusError) permanent() bool {
+	switch e.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return e.code >= 400 && e.code < 500
+}
+
+// parseRetryAfter parses the Retry-After header, which holds either seconds or an HTTP date.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
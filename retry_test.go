@@ -0,0 +1,53 @@
+package xlg
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Multiplier: 2}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if got := p.backoff(i + 1); got != e {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, e, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("expected backoff within 50%% of a second, got %v", got)
+		}
+	}
+}
+
+func TestStatusError_permanent(t *testing.T) {
+	tests := map[int]bool{400: true, 401: true, 404: true, 408: false, 429: false, 500: false, 503: false}
+	for code, expected := range tests {
+		if got := (&statusError{code: code}).permanent(); got != expected {
+			t.Errorf("%d: expected permanent %v, got %v", code, expected, got)
+		}
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in       string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-1", 0},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.expected {
+			t.Errorf("%q: expected %v, got %v", tt.in, tt.expected, got)
+		}
+	}
+}
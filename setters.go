@@ -7,14 +7,16 @@ import (
 	"net/http"
 	"net/url"
 	"runtime/debug"
+	"strings"
+	"time"
 )
 
 func (r Record) Fail(fn any, err error) Record {
-	return r.Msg("FAIL " + fnName(fn)).Err(err)
+	return r.Msg("FAIL " + fnName(fn)).Err(err).Lvl(LevelError)
 }
 
 func (r Record) Panic(fn, p any) Record {
-	return r.Msg("PANIC "+fnName(fn)).Err(fmt.Errorf("%v", p)).Attrs("stack", debug.Stack())
+	return r.Msg("PANIC "+fnName(fn)).Err(fmt.Errorf("%v", p)).Attrs("stack", debug.Stack()).Lvl(LevelFatal)
 }
 
 func (r Record) Msg(m string) Record {
@@ -22,11 +24,56 @@ func (r Record) Msg(m string) Record {
 	return r
 }
 
+func (r Record) Debug(m string) Record {
+	return r.Msg(m).Lvl(LevelDebug)
+}
+
+func (r Record) Info(m string) Record {
+	return r.Msg(m).Lvl(LevelInfo)
+}
+
+func (r Record) Warn(m string) Record {
+	return r.Msg(m).Lvl(LevelWarn)
+}
+
+func (r Record) Lvl(l Level) Record {
+	r.Level = l
+	return r
+}
+
 func (r Record) Ref(ref string) Record {
 	r.Reference = ref
 	return r
 }
 
+// ThrottleKey separates records with the same message and error in WriteEvery, WriteOnceIn and WriteRate,
+// e.g. to throttle each user separately: ThrottleKey(user).
+func (r Record) ThrottleKey(parts ...string) Record {
+	r.throttleKey = strings.Join(parts, "\x00")
+	return r
+}
+
+// Time overrides the event time, which is otherwise set when the record is written.
+func (r Record) Time(t time.Time) Record {
+	r.Timestamp = t
+	return r
+}
+
+// Since sets Duration to the time elapsed since start.
+func (r Record) Since(start time.Time) Record {
+	r.Duration = time.Since(start)
+	return r
+}
+
+// Trace joins the record to the span of a distributed trace.
+func (r Record) Trace(tc TraceContext) Record {
+	r.TraceID = tc.TraceID
+	r.SpanID = tc.SpanID
+	r.ParentSpanID = tc.ParentSpanID
+	r.TraceState = tc.State
+	return r
+}
+
 func (r Record) Err(e error) Record {
 	if e != nil {
 		r.Error = e.Error()
@@ -41,10 +88,12 @@ func (r Record) User(u string) Record {
 	return r
 }
 
+// Attrs sets attributes from key-value pairs, values of sensitive attributes are redacted.
 func (r Record) Attrs(args ...any) Record {
 	if r.Attributes == nil {
 		r.Attributes = make(map[string]string)
 	}
+	rd := r.logger().redactor()
 	var key string
 	for i, x := range args {
 		switch (i + 1) % 2 {
@@ -54,11 +103,11 @@ func (r Record) Attrs(args ...any) Record {
 			switch val := x.(type) {
 			default:
 				// %#v is a Go-syntax representation of the value
-				r.Attributes[key] = fmt.Sprintf("%#v", val)
+				r.Attributes[key] = rd.Attr(key, fmt.Sprintf("%#v", val))
 			case string:
 				// %#v for a string will add double quotes around the string
 				// use separate case for a string to avoid double quotes around value
-				r.Attributes[key] = val
+				r.Attributes[key] = rd.Attr(key, val)
 			}
 		}
 	}
@@ -81,17 +130,28 @@ func (r Record) Request(req *http.Request) Record {
 }
 
 func (r Record) Req(method string, url *url.URL, header http.Header, body []byte) Record {
+	return r.req(method, url, header, body, 0)
+}
+
+// req is Req for a body with a tail of dropped bytes which were not captured.
+func (r Record) req(method string, url *url.URL, header http.Header, body []byte, dropped int) Record {
+	// The reference received from another service links records of both services.
+	if ref := header.Get(r.logger().refHeader()); ref != "" {
+		r.Reference = ref
+	}
+	// A record which is not in the caller's trace yet becomes a child span of the caller.
+	// Records already in the trace are either the client span sent in the header or its child.
+	if tc, ok := traceFromHeader(header); ok && r.TraceID != tc.TraceID {
+		r = r.Trace(tc.Child())
+	}
+	rd := r.logger().redactor()
 	r.ReqMethod = method
 	if url != nil {
-		r.ReqURL = redactURL(url).String()
+		r.ReqURL = rd.URL(url).String()
 		r.ReqPath = url.Path
 	}
-	r.ReqHeader = head2str(redact(header))
-	compactBody, err := compactJSON(body)
-	if err == nil {
-		body = compactBody
-	}
-	r.ReqBody = string(truncate(body))
+	r.ReqHeader = head2str(rd.Header(header))
+	r.ReqBody = captureBody(rd, header, body, dropped)
 	return r
 }
 
@@ -111,12 +171,14 @@ func (r Record) Response(resp *http.Response) Record {
 }
 
 func (r Record) Resp(status int, header http.Header, body []byte) Record {
+	return r.resp(status, header, body, 0)
+}
+
+// resp is Resp for a body with a tail of dropped bytes which were not captured.
+func (r Record) resp(status int, header http.Header, body []byte, dropped int) Record {
+	rd := r.logger().redactor()
 	r.RespStatus = status
-	r.RespHeader = head2str(redact(header))
-	compactBody, err := compactJSON(body)
-	if err == nil {
-		body = compactBody
-	}
-	r.RespBody = string(truncate(body))
+	r.RespHeader = head2str(rd.Header(header))
+	r.RespBody = captureBody(rd, header, body, dropped)
 	return r
 }
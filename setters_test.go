@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func testFn() {}
@@ -20,6 +21,10 @@ func TestRecord_Fail(t *testing.T) {
 		t.Errorf("expected error '%s', got '%s'", err.Error(), l.Error)
 	}
 
+	if l.Level != LevelError {
+		t.Errorf("expected level '%s', got '%s'", LevelError, l.Level)
+	}
+
 	job := "job_name"
 	l = New().Fail(job, err)
 	if l.Message != "FAIL "+job {
@@ -39,6 +44,9 @@ func TestRecord_Panic(t *testing.T) {
 	if _, ok := l.Attributes["stack"]; !ok {
 		t.Errorf("expected stack trace in Attributes, but it is missing")
 	}
+	if l.Level != LevelFatal {
+		t.Errorf("expected level '%s', got '%s'", LevelFatal, l.Level)
+	}
 }
 
 func TestRecord_Msg(t *testing.T) {
@@ -49,6 +57,24 @@ func TestRecord_Msg(t *testing.T) {
 	}
 }
 
+func TestRecord_Levels(t *testing.T) {
+	tests := []struct {
+		record   Record
+		expected Level
+	}{
+		{New().Msg("m"), LevelInfo},
+		{New().Debug("m"), LevelDebug},
+		{New().Info("m"), LevelInfo},
+		{New().Warn("m"), LevelWarn},
+		{New().Lvl(LevelError), LevelError},
+	}
+	for _, tc := range tests {
+		if tc.record.Level != tc.expected {
+			t.Errorf("expected level '%s', got '%s'", tc.expected, tc.record.Level)
+		}
+	}
+}
+
 func TestRecord_Ref(t *testing.T) {
 	r := "reference"
 	l := New().Ref(r)
@@ -57,6 +83,21 @@ func TestRecord_Ref(t *testing.T) {
 	}
 }
 
+func TestRecord_Time(t *testing.T) {
+	ts := time.Date(2023, 9, 26, 17, 45, 53, 0, time.UTC)
+	l := New().Time(ts)
+	if !l.Timestamp.Equal(ts) {
+		t.Errorf("expected time '%s', got '%s'", ts, l.Timestamp)
+	}
+}
+
+func TestRecord_Since(t *testing.T) {
+	l := New().Since(time.Now().Add(-time.Second))
+	if l.Duration < time.Second {
+		t.Errorf("expected duration of at least 1s, got '%s'", l.Duration)
+	}
+}
+
 func TestRecord_Err(t *testing.T) {
 	e := errors.New("error")
 	l := New().Err(e)
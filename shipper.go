@@ -0,0 +1,112 @@
+package xlg
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path"
+)
+
+// Shipper delivers lines written by FileWriter to a collector.
+// A line is marked with SentMark only after it has been delivered,
+// so delivery is at-least-once and shipping resumes after a crash from the first unsent line.
+type Shipper struct {
+	// Dir is a directory where FileWriter stores log files.
+	Dir string
+
+	// Send delivers one log line without NotSentMark.
+	// The line is marked as sent only if Send returns nil.
+	// If the error wraps ErrRejected, e.g. HttpWriter got a 4xx response, the line is marked with RejectedMark
+	// and shipping continues with the next line.
+	Send func(line []byte) error
+
+	// offsets holds, per file, the position up to which every line is already marked as sent.
+	// It is kept in memory only: after a restart files are rescanned and sent lines are skipped by their mark.
+	offsets map[string]int64
+}
+
+// Ship makes one pass over the log files in Dir and returns the number of delivered lines.
+// It stops at the first failed Send to preserve order; the next pass continues from that line.
+func (s *Shipper) Ship() (n int, err error) {
+	names, err := logFiles(s.Dir)
+	if err != nil {
+		return 0, err
+	}
+	if s.offsets == nil {
+		s.offsets = make(map[string]int64)
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+		sent, err := s.shipFile(name)
+		n += sent
+		if err != nil {
+			return n, err
+		}
+	}
+	// Forget files removed from Dir to keep offsets bounded.
+	for name := range s.offsets {
+		if !seen[name] {
+			delete(s.offsets, name)
+		}
+	}
+	return n, nil
+}
+
+func (s *Shipper) shipFile(name string) (n int, err error) {
+	f, err := os.OpenFile(path.Join(s.Dir, name), os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	offset := s.offsets[name]
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without a newline is still being written, it is shipped in the next pass.
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if line[0] == NotSentMark {
+			mark := byte(SentMark)
+			if err := s.Send(line[1:]); errors.Is(err, ErrRejected) {
+				stderr.Printf("Shipper: %s at %d rejected: %v\n", name, offset, err)
+				mark = RejectedMark
+			} else if err != nil {
+				return n, err
+			}
+			if _, err := f.WriteAt([]byte{mark}, offset); err != nil {
+				return n, err
+			}
+			if mark == SentMark {
+				n++
+			}
+		}
+		offset += int64(len(line))
+		s.offsets[name] = offset
+	}
+}
+
+// logFiles returns names of uncompressed log files in dir in the order they were written.
+// Compressed files are fully sent, so they are skipped.
+func logFiles(dir string) ([]string, error) {
+	files, err := listLogFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.Compressed {
+			names = append(names, f.Name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,118 @@
+package xlg
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShipper_Ship(t *testing.T) {
+	t.Run("unsent lines are sent and marked", func(t *testing.T) {
+		dir := t.TempDir()
+		pathname := filepath.Join(dir, "2023-09-26")
+		check(os.WriteFile(pathname, []byte("+sent\n-first\n-second\n-partial"), 0644))
+
+		var got []string
+		s := Shipper{Dir: dir, Send: func(line []byte) error {
+			got = append(got, string(line))
+			return nil
+		}}
+		n, err := s.Ship()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 lines sent, but got %d", n)
+		}
+		if len(got) != 2 || got[0] != "first\n" || got[1] != "second\n" {
+			t.Errorf("expected first and second lines to be sent, but got %q", got)
+		}
+		content, err := os.ReadFile(pathname)
+		check(err)
+		if string(content) != "+sent\n+first\n+second\n-partial" {
+			t.Errorf("unexpected file content %q", content)
+		}
+	})
+
+	t.Run("failed send stops the pass and is retried", func(t *testing.T) {
+		dir := t.TempDir()
+		pathname := filepath.Join(dir, "2023-09-26")
+		check(os.WriteFile(pathname, []byte("-first\n-second\n"), 0644))
+
+		fail := true
+		var got []string
+		s := Shipper{Dir: dir, Send: func(line []byte) error {
+			if fail && string(line) == "second\n" {
+				return errors.New("collector is down")
+			}
+			got = append(got, string(line))
+			return nil
+		}}
+		if _, err := s.Ship(); err == nil {
+			t.Fatal("expected an error, but got nil")
+		}
+		content, err := os.ReadFile(pathname)
+		check(err)
+		if string(content) != "+first\n-second\n" {
+			t.Errorf("unexpected file content %q", content)
+		}
+
+		fail = false
+		// A new Shipper simulates a restarted agent without offsets in memory.
+		s = Shipper{Dir: dir, Send: s.Send}
+		n, err := s.Ship()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != 1 || got[len(got)-1] != "second\n" {
+			t.Errorf("expected only the second line to be resent, but got %q", got)
+		}
+	})
+
+	t.Run("line rejected by the collector is marked and skipped", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if b, _ := io.ReadAll(r.Body); string(b) == "{torn\n" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer srv.Close()
+
+		dir := t.TempDir()
+		pathname := filepath.Join(dir, "2023-09-26")
+		check(os.WriteFile(pathname, []byte("-{\"msg\":\"first\"}\n-{torn\n-{\"msg\":\"third\"}\n"), 0644))
+
+		w := &HttpWriter{URL: srv.URL}
+		s := Shipper{Dir: dir, Send: func(line []byte) error {
+			_, err := w.Write(line)
+			return err
+		}}
+		n, err := s.Ship()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 lines sent, but got %d", n)
+		}
+		content, err := os.ReadFile(pathname)
+		check(err)
+		if string(content) != "+{\"msg\":\"first\"}\n!{torn\n+{\"msg\":\"third\"}\n" {
+			t.Errorf("unexpected file content %q", content)
+		}
+	})
+
+	t.Run("files not named by FileFormat are ignored", func(t *testing.T) {
+		dir := t.TempDir()
+		check(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("-line\n"), 0644))
+		s := Shipper{Dir: dir, Send: func(line []byte) error {
+			t.Errorf("unexpected line %q", line)
+			return nil
+		}}
+		if _, err := s.Ship(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package xlg
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a slog.Handler writing Records through a Logger,
+// so services using log/slog land in the same pipeline as code using xlg directly.
+//
+// Attributes are flattened into Record.Attributes with group names joined by dots, e.g. "req.id".
+// Error-valued attributes are written to Record.Error.
+type SlogHandler struct {
+	lg *Logger
+	// prefix is the qualified name of the open groups followed by a dot.
+	prefix string
+}
+
+// NewSlogHandler returns a handler writing through l, nil stands for the default Logger.
+//
+//	slog.SetDefault(slog.New(xlg.NewSlogHandler(nil)))
+func NewSlogHandler(l *Logger) *SlogHandler {
+	if l == nil {
+		l = std
+	}
+	return &SlogHandler{lg: l}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.lg.Enabled(slogLevel(level))
+}
+
+// Handle writes the record with the reference and the active span of ctx, e.g. set by Middleware.
+func (h *SlogHandler) Handle(ctx context.Context, sr slog.Record) error {
+	r := h.lg.New()
+	if ctx != nil {
+		r = h.lg.FromContext(ctx)
+	}
+	r = r.Msg(sr.Message).Lvl(slogLevel(sr.Level))
+	if !sr.Time.IsZero() {
+		r.Timestamp = sr.Time.UTC()
+	}
+	sr.Attrs(func(a slog.Attr) bool {
+		r = addSlogAttr(r, h.prefix, a)
+		return true
+	})
+	h.lg.write(r, Rate{}, sr.PC)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	d := h.lg.derive()
+	for _, a := range attrs {
+		d.base = addSlogAttr(d.base, h.prefix, a)
+	}
+	return &SlogHandler{lg: d, prefix: h.prefix}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{lg: h.lg, prefix: h.prefix + name + "."}
+}
+
+// slogLevel maps slog levels onto xlg levels.
+// slog has no fatal level, levels from slog.LevelError+4 are considered fatal.
+func slogLevel(l slog.Level) Level {
+	switch {
+	case l < slog.LevelInfo:
+		return LevelDebug
+	case l < slog.LevelWarn:
+		return LevelInfo
+	case l < slog.LevelError:
+		return LevelWarn
+	case l < slog.LevelError+4:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+func addSlogAttr(r Record, prefix string, a slog.Attr) Record {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return r
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// A group with an empty key is inlined.
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			r = addSlogAttr(r, prefix, ga)
+		}
+		return r
+	}
+	if err, ok := a.Value.Any().(error); ok && r.Error == "" {
+		return r.Err(err)
+	}
+	return r.Attrs(prefix+a.Key, a.Value.String())
+}
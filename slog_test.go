@@ -0,0 +1,130 @@
+package xlg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+func TestSlogHandler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.SetLevel(LevelDebug)
+
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var r Record
+			check(json.Unmarshal(line, &r))
+			m := map[string]any{
+				slog.MessageKey: r.Message,
+				slog.LevelKey:   r.Level.String(),
+			}
+			if !r.Timestamp.IsZero() {
+				m[slog.TimeKey] = r.Timestamp
+			}
+			// Unflatten dotted attribute keys into nested groups as expected by slogtest.
+			for k, v := range r.Attributes {
+				parts := strings.Split(k, ".")
+				g := m
+				for _, p := range parts[:len(parts)-1] {
+					if _, ok := g[p].(map[string]any); !ok {
+						g[p] = map[string]any{}
+					}
+					g = g[p].(map[string]any)
+				}
+				g[parts[len(parts)-1]] = v
+			}
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	err := slogtest.TestHandler(NewSlogHandler(l), results)
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			// Records are always stamped when written, as the time is needed by the collector.
+			if strings.Contains(err.Error(), "zero Record.Time") {
+				continue
+			}
+			t.Error(err)
+		}
+	} else if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlogHandler_Record(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	logger := slog.New(NewSlogHandler(l)).With("service", "billing").WithGroup("req")
+
+	logger.Error("request failed", "id", 42, "err", errors.New("timeout"))
+	logger.Debug("dropped")
+
+	var r Record
+	check(json.Unmarshal(buf.Bytes(), &r))
+	if r.Message != "request failed" || r.Level != LevelError {
+		t.Errorf("expected error record 'request failed', got %+v", r)
+	}
+	if r.Error != "timeout" {
+		t.Errorf("expected error 'timeout', got %q", r.Error)
+	}
+	if r.Attributes["service"] != "billing" || r.Attributes["req.id"] != "42" {
+		t.Errorf("expected service and req.id attributes, got %v", r.Attributes)
+	}
+	if r.Source == nil || !strings.HasSuffix(r.Source.Func, "TestSlogHandler_Record") {
+		t.Errorf("expected source of the slog call, got %+v", r.Source)
+	}
+}
+
+func TestSlogHandler_Context(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tc := NewTrace()
+	ctx := ContextWithTrace(ContextWithRef(context.Background(), "ctx-ref"), tc)
+	slog.New(NewSlogHandler(NewLogger(buf))).With("service", "billing").InfoContext(ctx, "msg")
+
+	var r Record
+	check(json.Unmarshal(buf.Bytes(), &r))
+	if r.Reference != "ctx-ref" || r.TraceID != tc.TraceID || r.SpanID != tc.SpanID {
+		t.Errorf("expected reference and trace of the context, got %q, %q, %q", r.Reference, r.TraceID, r.SpanID)
+	}
+	if r.Attributes["service"] != "billing" {
+		t.Errorf("expected attributes of WithAttrs, got %v", r.Attributes)
+	}
+}
+
+func TestSlogHandler_WithAttrsRedacted(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.SetRedactor(&Redactor{Fields: []string{"secret"}})
+	slog.New(NewSlogHandler(l)).With("secret", "v").Info("msg")
+
+	var r Record
+	check(json.Unmarshal(buf.Bytes(), &r))
+	if r.Attributes["secret"] != redacted {
+		t.Errorf("expected attribute of WithAttrs redacted, got %v", r.Attributes)
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		input    slog.Level
+		expected Level
+	}{
+		{slog.LevelDebug, LevelDebug},
+		{slog.LevelInfo, LevelInfo},
+		{slog.LevelWarn, LevelWarn},
+		{slog.LevelError, LevelError},
+		{slog.LevelError + 4, LevelFatal},
+	}
+	for _, tc := range tests {
+		if l := slogLevel(tc.input); l != tc.expected {
+			t.Errorf("expected %s for %s, got %s", tc.expected, tc.input, l)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package xlg
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// throttleMaxKeys bounds the memory used by a throttle, least recently used keys are evicted first.
+	throttleMaxKeys = 10000
+	// Keys whose bucket is full again and which have nothing to report are evicted at most once per throttleEvictInterval.
+	throttleEvictInterval = time.Minute
+)
+
+// Rate limits records to N per Per, allowing bursts of up to Burst records.
+// It is a token bucket: it holds Burst tokens, every written record takes one and they are refilled at N per Per.
+// Zero Burst stands for N.
+//
+//	xlg.Rate{N: 10, Per: time.Minute}           // 10 records per minute, all of them may be written at once
+//	xlg.Rate{N: 1, Per: time.Second, Burst: 5} // 1 record per second on average, bursts of 5
+type Rate struct {
+	N     int
+	Per   time.Duration
+	Burst int
+}
+
+// Every returns the rate of one record per d, that is a record is written unless one was written within d.
+func Every(d time.Duration) Rate {
+	return Rate{N: 1, Per: d, Burst: 1}
+}
+
+// limited reports whether the rate limits anything, a zero or negative rate does not.
+func (r Rate) limited() bool {
+	return r.N > 0 && r.Per > 0
+}
+
+func (r Rate) burst() float64 {
+	if r.Burst <= 0 {
+		return float64(r.N)
+	}
+	return float64(r.Burst)
+}
+
+// key identifies records sharing a bucket: by default records with the same message and error,
+// or the same message only for the rate limit per message.
+type key struct {
+	msg   string
+	err   string
+	extra string
+}
+
+// throttleEntry is a token bucket of a key with records suppressed since the last written one.
+type throttleEntry struct {
+	key     key
+	rate    Rate
+	tokens  float64
+	updated time.Time
+	suppressed
+}
+
+// refill adds tokens accumulated since the last update.
+func (e *throttleEntry) refill(now time.Time) {
+	e.tokens += float64(now.Sub(e.updated)) / float64(e.rate.Per) * float64(e.rate.N)
+	e.tokens = min(e.tokens, e.rate.burst())
+	e.updated = now
+}
+
+// suppressed describes records which were not written because they exceeded the rate.
+type suppressed struct {
+	count       int
+	first, last time.Time
+}
+
+// merge combines records suppressed by different limits.
+func (s suppressed) merge(o suppressed) suppressed {
+	if o.count == 0 {
+		return s
+	}
+	if s.count == 0 {
+		return o
+	}
+	s.count += o.count
+	if o.first.Before(s.first) {
+		s.first = o.first
+	}
+	if o.last.After(s.last) {
+		s.last = o.last
+	}
+	return s
+}
+
+// throttle limits how often records with the same key are written.
+// It holds at most throttleMaxKeys keys. A key is forgotten once its bucket is full again unless it has suppressed records
+// to report, such keys are kept until the next write or until they become least recently used.
+type throttle struct {
+	mu sync.Mutex
+	// lru orders entries from the least to the most recently used.
+	lru     *list.List
+	entries map[key]*list.Element
+	evicted time.Time
+}
+
+// allow reports whether a record with the key may be written at the rate, then a token is taken
+// and the records suppressed since the previous write are returned to be reported by this one.
+func (t *throttle) allow(k key, rate Rate) (bool, suppressed) {
+	if !rate.limited() {
+		return true, suppressed{}
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[key]*list.Element)
+		t.lru = list.New()
+	}
+	if now.Sub(t.evicted) >= throttleEvictInterval {
+		t.evictExpired(now)
+	}
+
+	el, ok := t.entries[k]
+	if !ok {
+		el = t.lru.PushBack(&throttleEntry{key: k, rate: rate, tokens: rate.burst(), updated: now})
+		t.entries[k] = el
+		if t.lru.Len() > throttleMaxKeys {
+			t.remove(t.lru.Front())
+		}
+	}
+	t.lru.MoveToBack(el)
+	e := el.Value.(*throttleEntry)
+	// The rate of the latest record applies, e.g. when the caller changes it at runtime.
+	e.refill(now)
+	if e.rate != rate {
+		e.rate = rate
+		e.tokens = min(e.tokens, rate.burst())
+	}
+	if e.tokens < 1 {
+		if e.count == 0 {
+			e.first = now
+		}
+		e.count++
+		e.last = now
+		return false, suppressed{}
+	}
+	e.tokens--
+	s := e.suppressed
+	e.suppressed = suppressed{}
+	return true, s
+}
+
+// evictExpired removes keys whose bucket is full again without suppressed records, t.mu must be held.
+func (t *throttle) evictExpired(now time.Time) {
+	t.evicted = now
+	for el := t.lru.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*throttleEntry)
+		if e.refill(now); e.count == 0 && e.tokens >= e.rate.burst() {
+			t.remove(el)
+		}
+		el = next
+	}
+}
+
+func (t *throttle) remove(el *list.Element) {
+	delete(t.entries, el.Value.(*throttleEntry).key)
+	t.lru.Remove(el)
+}
@@ -0,0 +1,133 @@
+package xlg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_throttleSuppressed(t *testing.T) {
+	var tr throttle
+	k, rate := key{msg: "msg"}, Every(50*time.Millisecond)
+	tr.allow(k, rate)
+	for i := 0; i < 3; i++ {
+		if ok, _ := tr.allow(k, rate); ok {
+			t.Fatal("expected record within the period to be suppressed")
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	ok, s := tr.allow(k, rate)
+	if !ok || s.count != 3 || s.first.IsZero() || s.last.Before(s.first) {
+		t.Errorf("expected 3 suppressed records reported, got %v, %+v", ok, s)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, s = tr.allow(k, rate); s.count != 0 {
+		t.Errorf("expected suppressed count reset, got %d", s.count)
+	}
+}
+
+func Test_throttleBounded(t *testing.T) {
+	var tr throttle
+	for i := 0; i < throttleMaxKeys+10; i++ {
+		tr.allow(key{msg: fmt.Sprint(i)}, Every(time.Hour))
+	}
+	if len(tr.entries) != throttleMaxKeys || tr.lru.Len() != throttleMaxKeys {
+		t.Errorf("expected %d keys, got %d", throttleMaxKeys, len(tr.entries))
+	}
+	if _, ok := tr.entries[key{msg: "0"}]; ok {
+		t.Error("expected the least recently used key to be evicted")
+	}
+
+	// Keys whose period has elapsed are evicted, unless they have suppressed records to report.
+	tr.allow(key{msg: "short"}, Every(time.Millisecond))
+	tr.allow(key{msg: "0"}, Every(time.Hour))
+	tr.allow(key{msg: "0"}, Every(time.Hour))
+	time.Sleep(2 * time.Millisecond)
+	tr.evictExpired(time.Now())
+	if _, ok := tr.entries[key{msg: "short"}]; ok {
+		t.Error("expected expired key to be evicted")
+	}
+	if _, ok := tr.entries[key{msg: "0"}]; !ok {
+		t.Error("expected key with suppressed records to be kept")
+	}
+}
+
+func TestWriteOnceIn_suppressedAttrs(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	for i := 0; i < 3; i++ {
+		l.Msg("retry").WriteOnceIn("50ms")
+	}
+	time.Sleep(60 * time.Millisecond)
+	l.Msg("retry").WriteOnceIn("50ms")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(lines))
+	}
+	var r Record
+	check(json.Unmarshal([]byte(lines[1]), &r))
+	if r.Attributes["xlg_suppressed"] != "2" || r.Attributes["xlg_suppressed_first"] == "" || r.Attributes["xlg_suppressed_last"] == "" {
+		t.Errorf("expected suppressed attributes, got %v", r.Attributes)
+	}
+}
+
+func Test_throttleBurst(t *testing.T) {
+	var tr throttle
+	k, rate := key{msg: "msg"}, Rate{N: 1, Per: 50 * time.Millisecond, Burst: 3}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := tr.allow(k, rate); ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected burst of 3 records, got %d", allowed)
+	}
+	time.Sleep(60 * time.Millisecond)
+	ok, s := tr.allow(k, rate)
+	if !ok || s.count != 7 {
+		t.Errorf("expected a refilled token and 7 suppressed records, got %v, %d", ok, s.count)
+	}
+	if ok, _ := tr.allow(k, rate); ok {
+		t.Error("expected one token to be refilled")
+	}
+}
+
+func TestRecord_ThrottleKey(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	for _, user := range []string{"a", "b", "a", "b"} {
+		l.Msg("login failed").ThrottleKey(user).WriteEvery(time.Hour)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("expected a record per user, got %d", n)
+	}
+}
+
+func TestLogger_SetRateLimit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.SetRateLimit(Rate{N: 2, Per: time.Hour})
+	for i := 0; i < 5; i++ {
+		l.Msg("storm").Err(fmt.Errorf("error %d", i)).Write()
+	}
+	l.Msg("other").Write()
+	if n := strings.Count(buf.String(), "\n"); n != 3 {
+		t.Errorf("expected 2 records of the storm and 1 other, got %d", n)
+	}
+}
+
+func TestWriteOnceIn_invalidPeriod(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewLogger(buf)
+	l.Msg("msg").WriteOnceIn("invalid")
+	l.Msg("msg").WriteOnceIn("invalid")
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("expected records with invalid period written, got %d", n)
+	}
+}
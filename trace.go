@@ -0,0 +1,138 @@
+package xlg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Header names defined by W3C Trace Context.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// FlagSampled is the trace flag which tells that the caller may have recorded the trace.
+const FlagSampled byte = 0x01
+
+// TraceContext identifies a span of a distributed trace as defined by W3C Trace Context,
+// so records join traces without a dependency on any tracing SDK.
+type TraceContext struct {
+	TraceID      string // 32 lowercase hex characters
+	SpanID       string // 16 lowercase hex characters
+	ParentSpanID string // empty for a root span
+	Flags        byte
+	// State is the vendor-specific tracestate header, it is propagated as is.
+	State string
+}
+
+// NewTrace starts a new sampled trace with a root span.
+func NewTrace() TraceContext {
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: FlagSampled}
+}
+
+// Child returns a new span of the trace whose parent is tc.
+func (tc TraceContext) Child() TraceContext {
+	return TraceContext{
+		TraceID:      tc.TraceID,
+		SpanID:       randomHex(8),
+		ParentSpanID: tc.SpanID,
+		Flags:        tc.Flags,
+		State:        tc.State,
+	}
+}
+
+// Traceparent returns the value of the traceparent header identifying the span.
+func (tc TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// ParseTraceparent parses the value of the traceparent header.
+// The returned span is the caller's span, use Child to continue the trace.
+func ParseTraceparent(s string) (tc TraceContext, err error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return tc, fmt.Errorf("traceparent: expected 4 fields, got %d", len(parts))
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	switch {
+	case !isHex(version, 2) || version == "ff":
+		return tc, fmt.Errorf("traceparent: invalid version %q", version)
+	// Future versions may append fields, version 00 has exactly 4.
+	case version == "00" && len(parts) != 4:
+		return tc, fmt.Errorf("traceparent: expected 4 fields, got %d", len(parts))
+	case !isHex(traceID, 32) || traceID == strings.Repeat("0", 32):
+		return tc, fmt.Errorf("traceparent: invalid trace id %q", traceID)
+	case !isHex(spanID, 16) || spanID == strings.Repeat("0", 16):
+		return tc, fmt.Errorf("traceparent: invalid parent id %q", spanID)
+	case !isHex(flags, 2):
+		return tc, fmt.Errorf("traceparent: invalid flags %q", flags)
+	}
+	f, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Flags: f[0]}, nil
+}
+
+// traceFromHeader returns the caller's span from traceparent and tracestate headers.
+func traceFromHeader(h http.Header) (TraceContext, bool) {
+	tc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return tc, false
+	}
+	tc.State = h.Get(TracestateHeader)
+	return tc, true
+}
+
+// setHeader sets traceparent and tracestate headers to propagate the span.
+func (tc TraceContext) setHeader(h http.Header) {
+	h.Set(TraceparentHeader, tc.Traceparent())
+	if tc.State != "" {
+		h.Set(TracestateHeader, tc.State)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// ContextWithTrace returns a copy of ctx carrying the active span,
+// so records created with FromContext down the call chain join the trace.
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceKey, tc)
+}
+
+// TraceFromContext returns the active span stored in ctx.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey).(TraceContext)
+	return tc, ok
+}
+
+// InjectTrace sets traceparent and tracestate headers on an outgoing request
+// to a child of the span stored in the request context. Transport does it automatically.
+func InjectTrace(req *http.Request) {
+	if tc, ok := TraceFromContext(req.Context()); ok {
+		tc.Child().setHeader(req.Header)
+	}
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes in hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		stderr.Printf("randomHex: %v\n", err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,103 @@
+package xlg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{name: "valid", input: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true},
+		{name: "future version with extra field", input: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: true},
+		{name: "version 00 with extra field", input: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "forbidden version", input: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", input: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero parent id", input: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "uppercase", input: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "short trace id", input: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+		{name: "empty", input: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := ParseTraceparent(tc.input)
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", tc.valid, err)
+			}
+			if tc.valid && (res.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || res.SpanID != "00f067aa0ba902b7" || res.Flags != FlagSampled) {
+				t.Errorf("unexpected result %+v", res)
+			}
+		})
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	root := NewTrace()
+	if !isHex(root.TraceID, 32) || !isHex(root.SpanID, 16) || root.ParentSpanID != "" {
+		t.Fatalf("unexpected root span %+v", root)
+	}
+	child := root.Child()
+	if child.TraceID != root.TraceID || child.ParentSpanID != root.SpanID || child.SpanID == root.SpanID {
+		t.Errorf("unexpected child span %+v of %+v", child, root)
+	}
+	parsed, err := ParseTraceparent(child.Traceparent())
+	if err != nil || parsed.TraceID != child.TraceID || parsed.SpanID != child.SpanID {
+		t.Errorf("expected traceparent to round trip, got %+v, %v", parsed, err)
+	}
+
+	ctx := ContextWithTrace(context.Background(), child)
+	r := FromContext(ctx)
+	if r.TraceID != child.TraceID || r.SpanID != child.SpanID || r.ParentSpanID != root.SpanID {
+		t.Errorf("expected record in the span of the context, got %+v", r)
+	}
+}
+
+func TestRecord_ReqTrace(t *testing.T) {
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Set(TracestateHeader, "vendor=value")
+	r := New().Req(http.MethodGet, nil, h, nil)
+	if r.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || r.ParentSpanID != "00f067aa0ba902b7" || r.SpanID == "" {
+		t.Errorf("expected record to be a child of the caller's span, got %+v", r)
+	}
+	if r.TraceState != "vendor=value" {
+		t.Errorf("expected tracestate %q, got %q", "vendor=value", r.TraceState)
+	}
+}
+
+func TestTrace_acrossServices(t *testing.T) {
+	serverLog, clientLog := new(bytes.Buffer), new(bytes.Buffer)
+	srv := httptest.NewServer(Middleware{Logger: NewLogger(serverLog)}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer srv.Close()
+
+	parent := NewTrace()
+	ctx := ContextWithTrace(context.Background(), parent)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	c := &http.Client{Transport: &Transport{Logger: NewLogger(clientLog)}}
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	var client, server Record
+	check(json.Unmarshal(clientLog.Bytes(), &client))
+	check(json.Unmarshal(serverLog.Bytes(), &server))
+	if client.TraceID != parent.TraceID || server.TraceID != parent.TraceID {
+		t.Errorf("expected one trace %q, got %q and %q", parent.TraceID, client.TraceID, server.TraceID)
+	}
+	if client.ParentSpanID != parent.SpanID {
+		t.Errorf("expected client span to be a child of %q, got %q", parent.SpanID, client.ParentSpanID)
+	}
+	if server.ParentSpanID != client.SpanID {
+		t.Errorf("expected server span to be a child of %q, got %q", client.SpanID, server.ParentSpanID)
+	}
+	if server.Reference != client.Reference {
+		t.Errorf("expected one reference, got %q and %q", client.Reference, server.Reference)
+	}
+}
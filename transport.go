@@ -0,0 +1,135 @@
+package xlg
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// Transport is an http.RoundTripper logging every outbound exchange as a single Record
+// in the same way as Request(req).Response(resp), including transport errors and latency.
+// Bodies are captured up to bodyMaxBytes as they are sent and read, so the exchange is logged
+// when the caller reads the response body to the end or closes it, which the caller must do anyway.
+// The record Reference is sent in the ref header (DefaultRefHeader unless changed with SetRefHeader),
+// so the callee logs with the same reference. The reference is taken from the request context if present.
+// The exchange is traced as a span of the trace in the request context, see InjectTrace.
+//
+//	client := &http.Client{Transport: &xlg.Transport{Filter: xlg.Non2xx}}
+type Transport struct {
+	// Base performs the requests, nil stands for http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Logger writes the records, nil stands for the default Logger.
+	Logger *Logger
+
+	// Filter reports whether the exchange is logged, nil logs all exchanges.
+	// The response is nil when err is not nil.
+	Filter func(req *http.Request, resp *http.Response, err error) bool
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	lg := t.Logger
+	if lg == nil {
+		lg = std
+	}
+
+	l := lg.FromContext(req.Context())
+	// RoundTripper must not modify the request, so the header is set on a clone.
+	out := req.Clone(req.Context())
+	if h := lg.refHeader(); out.Header.Get(h) == "" {
+		out.Header.Set(h, l.Reference)
+	}
+	// The exchange is a client span: the one set by the caller in traceparent,
+	// a child of the active span in the context or a root of a new trace.
+	tc, ok := traceFromHeader(out.Header)
+	if !ok {
+		if parent, ok := TraceFromContext(req.Context()); ok {
+			tc = parent.Child()
+		} else {
+			tc = NewTrace()
+		}
+		tc.setHeader(out.Header)
+	}
+	l = l.Trace(tc)
+	// The request body is captured up to bodyMaxBytes while the base transport sends it.
+	// Closing the body of the clone closes the original one.
+	var reqBody *capture
+	if req.Body != nil && req.Body != http.NoBody {
+		reqBody = new(capture)
+		out.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(req.Body, reqBody), req.Body}
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(out)
+	if t.Filter != nil && !t.Filter(req, resp, err) {
+		return resp, err
+	}
+	// Request takes the reference from the header set by the caller if any.
+	l = l.req(out.Method, out.URL, out.Header, reqBody.bytes(), reqBody.droppedBytes())
+	if err != nil {
+		l.Err(err).Lvl(LevelError).Since(start).Write()
+		return resp, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		l = l.Lvl(LevelError)
+	}
+	// The body of a protocol switch is the connection, it is not wrapped.
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		l.resp(resp.StatusCode, resp.Header, nil, 0).Since(start).Write()
+		return resp, err
+	}
+	// The response body is captured while the caller reads it, so streaming responses are not buffered,
+	// and the exchange is logged once the body is read to the end or closed.
+	b := &loggedBody{ReadCloser: resp.Body}
+	b.done = func() {
+		l.resp(resp.StatusCode, resp.Header, b.c.bytes(), b.c.droppedBytes()).Since(start).Write()
+	}
+	resp.Body = b
+	return resp, err
+}
+
+// loggedBody is a response body which is captured as it is read.
+type loggedBody struct {
+	io.ReadCloser
+	c    capture
+	once sync.Once
+	done func()
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.c.Write(p[:n])
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// Non2xx is a Transport filter which logs only failed exchanges: transport errors and non-2xx responses.
+func Non2xx(_ *http.Request, resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300
+}
+
+// HostMatch returns a Transport filter which logs only exchanges with hosts matching the pattern.
+// The pattern syntax is that of path.Match, e.g. "*.example.com".
+func HostMatch(pattern string) func(*http.Request, *http.Response, error) bool {
+	return func(req *http.Request, _ *http.Response, _ error) bool {
+		ok, _ := path.Match(pattern, req.URL.Hostname())
+		return ok
+	}
+}
@@ -0,0 +1,243 @@
+package xlg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	var gotRef string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRef = r.Header.Get(DefaultRefHeader)
+		b, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	t.Run("exchange is logged", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf)}}
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/items", strings.NewReader(`{"key": "value"}`))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != `{"key": "value"}` {
+			t.Errorf("expected response body to be readable by the caller, got %q", body)
+		}
+		if req.Header.Get(DefaultRefHeader) != "" {
+			t.Error("expected the original request to be unchanged")
+		}
+
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Message != "POST /items [200]" {
+			t.Errorf("expected msg %q, got %q", "POST /items [200]", l.Message)
+		}
+		if l.ReqBody != `{"key":"value"}` || l.RespBody != `{"key":"value"}` {
+			t.Errorf("expected bodies, got %q and %q", l.ReqBody, l.RespBody)
+		}
+		if gotRef == "" || gotRef != l.Reference {
+			t.Errorf("expected reference %q in %s, got %q", l.Reference, DefaultRefHeader, gotRef)
+		}
+	})
+
+	t.Run("reference of the request is kept", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf)}}
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set(DefaultRefHeader, "ref")
+		if _, err := c.Do(req); err != nil {
+			t.Fatal(err)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Reference != "ref" || gotRef != "ref" {
+			t.Errorf("expected reference %q, got %q and %q", "ref", l.Reference, gotRef)
+		}
+	})
+
+	t.Run("reference of the context is propagated", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf)}}
+		req, _ := http.NewRequestWithContext(ContextWithRef(context.Background(), "ctx-ref"), http.MethodGet, srv.URL, nil)
+		if _, err := c.Do(req); err != nil {
+			t.Fatal(err)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Reference != "ctx-ref" || gotRef != "ctx-ref" {
+			t.Errorf("expected reference %q, got %q and %q", "ctx-ref", l.Reference, gotRef)
+		}
+	})
+
+	t.Run("transport error is logged", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		failing := roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+		c := &http.Client{Transport: &Transport{Base: failing, Logger: NewLogger(buf)}}
+		if _, err := c.Get("http://example.com/items"); err == nil {
+			t.Fatal("expected an error, but got nil")
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.Error != "connection refused" || l.Level != LevelError {
+			t.Errorf("expected error record, got %+v", l)
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf), Filter: Non2xx}}
+		if _, err := c.Get(srv.URL + "/ok"); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected 2xx exchange not to be logged, got %q", buf.String())
+		}
+		if _, err := c.Get(srv.URL + "/fail"); err != nil {
+			t.Fatal(err)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.RespStatus != http.StatusBadGateway || l.Level != LevelError {
+			t.Errorf("expected 502 error record, got %+v", l)
+		}
+	})
+}
+
+func TestTransport_body(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		switch r.URL.Path {
+		case "/stream":
+			w.Write([]byte("first\n"))
+			w.(http.Flusher).Flush()
+			<-release
+			w.Write([]byte("second\n"))
+		case "/large":
+			w.Write([]byte(strings.Repeat("a", bodyMaxBytes+10)))
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("streaming response is logged when the body is read to the end", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf)}}
+		resp, err := c.Get(srv.URL + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if buf.Len() != 0 {
+			t.Errorf("expected no record before the body is read, got %q", buf.String())
+		}
+		close(release)
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "first\nsecond\n" {
+			t.Errorf("expected response body to be readable by the caller, got %q", body)
+		}
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.RespBody != "first\nsecond\n" {
+			t.Errorf("expected streamed body, got %q", l.RespBody)
+		}
+	})
+
+	t.Run("bodies are truncated", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf)}}
+		resp, err := c.Post(srv.URL+"/large", "text/plain", strings.NewReader(strings.Repeat("b", bodyMaxBytes+20)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if !strings.HasSuffix(l.ReqBody, "...xlg_truncated 20 bytes") || !strings.HasSuffix(l.RespBody, "...xlg_truncated 10 bytes") {
+			t.Errorf("expected truncated bodies, got %q and %q", l.ReqBody[bodyMaxBytes:], l.RespBody[bodyMaxBytes:])
+		}
+	})
+
+	t.Run("fields of a large body are redacted", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		lg := NewLogger(buf)
+		lg.SetRedactor(&Redactor{Fields: []string{"password"}})
+		c := &http.Client{Transport: &Transport{Logger: lg}}
+		body := `{"password":"hunter2","data":"` + strings.Repeat("b", bodyMaxBytes) + `"}`
+		resp, err := c.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if strings.Contains(l.ReqBody, "hunter2") || !strings.HasPrefix(l.ReqBody, `{"password":"...xlg_redacted...","data":"bbb`) {
+			t.Errorf("expected password redacted, got %q", l.ReqBody[:40])
+		}
+	})
+
+	t.Run("exchange is logged when the body is closed unread", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf)}}
+		resp, err := c.Get(srv.URL + "/large")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		var l Record
+		check(json.Unmarshal(buf.Bytes(), &l))
+		if l.RespStatus != http.StatusOK {
+			t.Errorf("expected 200 record, got %+v", l)
+		}
+	})
+
+	t.Run("filtered response is not captured", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		c := &http.Client{Transport: &Transport{Logger: NewLogger(buf), Filter: Non2xx}}
+		resp, err := c.Get(srv.URL + "/large")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := resp.Body.(*loggedBody); ok {
+			t.Error("expected the body of a filtered exchange not to be wrapped")
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if buf.Len() != 0 {
+			t.Errorf("expected 2xx exchange not to be logged, got %q", buf.String())
+		}
+	})
+}
+
+func TestHostMatch(t *testing.T) {
+	f := HostMatch("*.example.com")
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com:8443/path", nil)
+	if !f(req, nil, nil) {
+		t.Error("expected host to match")
+	}
+	req, _ = http.NewRequest(http.MethodGet, "https://example.org/path", nil)
+	if f(req, nil, nil) {
+		t.Error("expected host not to match")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -6,12 +6,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"net/http"
-	"net/url"
 	"reflect"
 	"runtime"
-	"strings"
 )
 
 // fnName retrieves type of the provided function or interface value.
@@ -33,14 +30,16 @@ func fnName(fn any) string {
 	}
 }
 
-const hexLetters = "abcdef0123456789"
-
-func uuid() string {
-	b := make([]byte, 36)
-	for i := range b {
-		b[i] = hexLetters[rand.Intn(len(hexLetters))]
+// cloneAttrs returns a copy of attributes, so records and Loggers do not share a map.
+func cloneAttrs(attrs map[string]string) map[string]string {
+	if attrs == nil {
+		return nil
+	}
+	c := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		c[k] = v
 	}
-	return string(b)
+	return c
 }
 
 // Usually the size of rather big body is 2KB (example in testdata),
@@ -49,11 +48,22 @@ const bodyMaxBytes = 5 * 1 << 10
 
 // truncate truncates the provided byte slice to bodyMaxBytes
 func truncate(body []byte) []byte {
+	return truncateDropped(body, 0)
+}
+
+// truncateDropped truncates a body whose tail of dropped bytes was not captured at all,
+// e.g. by the Middleware which limits memory used per request.
+func truncateDropped(body []byte, dropped int) []byte {
 	if len(body) > bodyMaxBytes {
-		b := body[:bodyMaxBytes]
-		return append(b, []byte(fmt.Sprintf("...xlg_truncated %d bytes", len(body)-len(b)))...)
+		dropped += len(body) - bodyMaxBytes
+		body = body[:bodyMaxBytes]
 	}
-	return body
+	if dropped == 0 {
+		return body
+	}
+	// Full slice expression makes append copy the body instead of overwriting the caller's bytes.
+	body = body[:len(body):len(body)]
+	return append(body, []byte(fmt.Sprintf("...xlg_truncated %d bytes", dropped))...)
 }
 
 // head2str converts http.Header to a string.
@@ -72,48 +82,6 @@ func head2str(h http.Header) string {
 	return b.String()
 }
 
-// must be safe for use in header and url
-const redacted = "...xlg_redacted..."
-
-func redact(h http.Header) (c http.Header) {
-	if h == nil {
-		return nil
-	}
-	c = h.Clone()
-	for k := range c {
-		edit := true
-
-		switch kl := strings.ToLower(k); {
-		case kl == "authorization":
-		case strings.Contains(kl, "password"):
-		case strings.Contains(kl, "secret"):
-		case strings.Contains(kl, "token"):
-		case strings.Contains(kl, "key"):
-		default:
-			edit = false
-		}
-
-		if edit {
-			c[k] = []string{redacted}
-		}
-	}
-	return c
-}
-
-func redactURL(u *url.URL) *url.URL {
-	if u == nil {
-		return nil
-	}
-	c := *u
-	for k := range c.Query() {
-		switch k {
-		case "token", "password":
-			c.RawQuery = strings.Replace(c.RawQuery, c.Query().Get(k), redacted, 1)
-		}
-	}
-	return &c
-}
-
 // httpMsg returns a string representation of an HTTP transaction: request and optionally response.
 // If the log includes only a request, the method and path are included in the message.
 // If a response status is provided and is not zero, it is also included in the message.
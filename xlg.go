@@ -1,64 +1,85 @@
 package xlg
 
 import (
-	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"runtime"
-	"sync"
 	"time"
 )
 
-var (
-	writer io.Writer = os.Stdout
-	stderr           = log.New(os.Stderr, "xlg: ", log.Flags())
-)
+var stderr = log.New(os.Stderr, "xlg: ", log.Flags())
+
+// std is the default Logger used by package-level functions.
+var std = NewLogger(os.Stdout)
+
+// Default returns the Logger used by package-level functions.
+func Default() *Logger {
+	return std
+}
 
-// todo add count or tries in attrs when writer is throttled
 func SetOutput(w io.Writer) {
-	writer = w
+	std.SetOutput(w)
+}
+
+// SetLevel sets the minimum level of records written by the default Logger. It is safe to call at runtime.
+func SetLevel(l Level) {
+	std.SetLevel(l)
+}
+
+// SetRateLimit limits every message written by the default Logger to the rate, see Logger.SetRateLimit.
+func SetRateLimit(rate Rate) {
+	std.SetRateLimit(rate)
+}
+
+// User returns a Logger which sets the user of every record it creates.
+func User(u string) *Logger {
+	return std.User(u)
 }
 
 func New() Record {
-	return newRecord()
+	return std.New()
 }
 
 func Msg(m string) Record {
-	return newRecord().Msg(m)
+	return std.Msg(m)
+}
+
+func Debug(m string) Record {
+	return std.Debug(m)
+}
+
+func Info(m string) Record {
+	return std.Info(m)
+}
+
+func Warn(m string) Record {
+	return std.Warn(m)
 }
 
 // Request creates a Record based on an HTTP request. There is no separate Response constructor,
 // as it is common practice to log both the request and response together to provide comprehensive
 // context in log entries.
+// The reference received from another service in the ref header (X-Request-ID by default) is kept.
 func Request(r *http.Request) Record {
-	return newRecord().Request(r)
+	return std.Request(r)
 }
 
 // Req allows you to create a Record with a redacted request body/headers/url.
 // This is useful, for example, when handling requests that contain large files or
 // sensitive information that should not be included in the logged data.
 func Req(method string, url *url.URL, header http.Header, body []byte) Record {
-	return newRecord().Req(method, url, header, body)
+	return std.Req(method, url, header, body)
 }
 
 func Fail(fn any, err error) Record {
-	return newRecord().Fail(fn, err)
+	return std.Fail(fn, err)
 }
 
 func Panic(fn, p any) Record {
-	return newRecord().Panic(fn, p)
-}
-
-func newRecord() Record {
-	host, _ := os.Hostname()
-	return Record{
-		Hostname:    host,
-		Environment: os.Getenv("ENVIRONMENT"),
-		Reference:   uuid(),
-	}
+	return std.Panic(fn, p)
 }
 
 type Record struct {
@@ -83,6 +104,30 @@ type Record struct {
 	// Additionally, it serves to link logs across different services collaborating to respond to a request.
 	Reference string `json:"ref,omitempty"`
 
+	// TraceID, SpanID and ParentSpanID join the record to a distributed trace as defined by W3C Trace Context.
+	// For a logged HTTP exchange the span is the exchange itself: the server span is a child of the caller's span,
+	// and the client span is the one sent in the traceparent header.
+	TraceID      string `json:"trace_id,omitempty"`
+	SpanID       string `json:"span_id,omitempty"`
+	ParentSpanID string `json:"parent_span_id,omitempty"`
+
+	// TraceState is the vendor-specific tracestate header propagated with the trace.
+	TraceState string `json:"trace_state,omitempty"`
+
+	// Timestamp is the time of the event, encoded in RFC3339Nano format.
+	// It is set by Write unless already provided with Time, e.g. for replayed events.
+	// The collector's receive time is not a substitute, as FileWriter logs may be shipped hours later.
+	Timestamp time.Time `json:"time"`
+
+	// Duration is the elapsed time of the logged operation in nanoseconds, e.g. latency of an HTTP exchange.
+	// It is set with Since, which relies on the monotonic clock.
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Level is the severity of the record.
+	// Fail and Panic set error and fatal levels, other records are info unless set explicitly.
+	// Info is omitted, so info records keep the format of records written before levels were introduced.
+	Level Level `json:"level,omitempty"`
+
 	Message string `json:"msg"`
 	Error   string `json:"err,omitempty"`
 
@@ -114,6 +159,12 @@ type Record struct {
 	RespHeader string  `json:"resp_header,omitempty"`
 	RespBody   string  `json:"resp_body,omitempty"`
 	Source     *Source `json:"source,omitempty"`
+
+	// lg is the Logger which created the record, nil stands for the default Logger.
+	lg *Logger
+
+	// throttleKey separates records with the same message and error in throttling, see ThrottleKey.
+	throttleKey string
 }
 
 type Source struct {
@@ -122,66 +173,47 @@ type Source struct {
 	Line int    `json:"line"`
 }
 
-type key struct {
-	msg string
-	err string
-}
-
-var (
-	// todo memory leak, as cache grows indefinitely
-	cache = make(map[key]time.Time)
-	mu    sync.Mutex
-)
-
 func (r Record) Write() {
-	r.WriteOnceIn("")
+	r.write(Rate{})
 }
 
+// WriteOnceIn writes the record unless a record with the same message and error was written within the period,
+// e.g. "1m". The first record written after the period reports the number and times of suppressed records in attributes.
+// An invalid period is reported to stderr and the record is written, prefer WriteEvery.
 func (r Record) WriteOnceIn(period string) {
-	if r.Message == "" {
-		if r.ReqMethod != "" && r.ReqPath != "" {
-			r.Message = httpMsg(r.ReqMethod, r.ReqPath, r.RespStatus)
-		} else {
-			r.Message = "xlog_empty"
-		}
+	d, err := time.ParseDuration(period)
+	if err != nil {
+		stderr.Printf("WriteOnceIn: %v\n", err)
 	}
+	r.write(Every(d))
+}
 
-	if period != "" && writeOccurredWithin(period, r.Message, r.Error) {
-		return
-	}
+// WriteEvery writes the record unless a record with the same message, error and ThrottleKey was written within d.
+// The first record written after d reports the number and times of suppressed records in attributes.
+func (r Record) WriteEvery(d time.Duration) {
+	r.write(Every(d))
+}
 
-	var pcs [1]uintptr
-	runtime.Callers(3, pcs[:]) // skip [Callers, WriteOnceIn, Write]
-	fs := runtime.CallersFrames([]uintptr{pcs[0]})
-	f, _ := fs.Next()
-	r.Source = &Source{
-		Func: f.Function,
-		File: f.File,
-		Line: f.Line,
-	}
+// WriteRate writes records with the same message, error and ThrottleKey at most at the rate.
+// The first record written after suppressed ones reports their number and times in attributes.
+func (r Record) WriteRate(rate Rate) {
+	r.write(rate)
+}
 
-	// encoder does not escape html (<, >, &)
-	// encoder adds newline at the end
-	enc := json.NewEncoder(writer)
-	enc.SetEscapeHTML(false)
-	if err := enc.Encode(r); err != nil {
-		stderr.Println("failed to encode record:", err)
+func (r Record) write(rate Rate) {
+	l := r.logger()
+	// Drop records below the minimum level before any work is done on them.
+	if !l.Enabled(r.Level) {
 		return
 	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip [Callers, write, Write or another Write method]
+	l.write(r, rate, pcs[0])
 }
 
-func writeOccurredWithin(period, message, error string) bool {
-	d, err := time.ParseDuration(period)
-	if err != nil {
-		return false
-	}
-	mu.Lock()
-	defer mu.Unlock()
-	if lastWrite, ok := cache[key{message, error}]; ok {
-		if time.Since(lastWrite) < d {
-			return true
-		}
+func (r Record) logger() *Logger {
+	if r.lg == nil {
+		return std
 	}
-	cache[key{message, error}] = time.Now()
-	return false
+	return r.lg
 }
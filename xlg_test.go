@@ -3,6 +3,7 @@ package xlg
 import (
 	"bytes"
 	"encoding/json"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -16,8 +17,8 @@ func check(err error) {
 
 func TestWriteAddsSource(t *testing.T) {
 	buf := new(bytes.Buffer)
-	writer = buf
-	defer func() { writer = nil }()
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
 
 	New().Write()
 	var l Record
@@ -34,38 +35,37 @@ func TestWriteAddsSource(t *testing.T) {
 	}
 }
 
-func Test_writeOccurredWithin(t *testing.T) {
-	message := "message"
-	err := "error"
-	period := "1s"
+func Test_throttleAllow(t *testing.T) {
+	k := key{msg: "message", err: "error"}
+	period := Every(time.Second)
 
-	res := writeOccurredWithin(period, message, err)
-	if res {
-		t.Error("Test case 1: expected false for the first write, but got true")
+	var tr throttle
+	res, _ := tr.allow(k, period)
+	if !res {
+		t.Error("Test case 1: expected true for the first write, but got false")
 	}
 
-	res = writeOccurredWithin(period, message, err)
-	if !res {
-		t.Error("Test case 2: expected true for the second write of the same log, but got false")
+	res, _ = tr.allow(k, period)
+	if res {
+		t.Error("Test case 2: expected false for the second write of the same log, but got true")
 	}
 
 	time.Sleep(2 * time.Second)
-	res = writeOccurredWithin(period, message, err)
-	if res {
-		t.Error("Test case 3: expected false for the third write after the period has elapsed, but got true")
+	res, _ = tr.allow(k, period)
+	if !res {
+		t.Error("Test case 3: expected true for the third write after the period has elapsed, but got false")
 	}
 
-	invalidPeriod := "invalid"
-	res = writeOccurredWithin(invalidPeriod, message, err)
-	if res {
-		t.Error("Test case 4: expected false for an invalid period, but got true")
+	res, _ = tr.allow(k, Rate{})
+	if !res {
+		t.Error("Test case 4: expected true for a zero rate, but got false")
 	}
 }
 
 func TestWrite_httpMsg(t *testing.T) {
 	buf := new(bytes.Buffer)
-	writer = buf
-	defer func() { writer = nil }()
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
 
 	rec := Record{
 		Message:    "",
@@ -84,8 +84,8 @@ func TestWrite_httpMsg(t *testing.T) {
 
 func TestWriteEncoder(t *testing.T) {
 	buf := new(bytes.Buffer)
-	writer = buf
-	defer func() { writer = nil }()
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
 
 	rec := Record{Message: "<test>"}
 	rec.Write()
@@ -97,3 +97,24 @@ func TestWriteEncoder(t *testing.T) {
 		t.Errorf("want newline at the end, got %q", buf.String())
 	}
 }
+
+func TestWriteSetsTime(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	before := time.Now()
+	New().Write()
+	var l Record
+	check(json.Unmarshal(buf.Bytes(), &l))
+	if l.Timestamp.Before(before) || l.Timestamp.After(time.Now()) {
+		t.Errorf("expected time of the write, got %s", l.Timestamp)
+	}
+
+	buf.Reset()
+	ts := time.Date(2023, 9, 26, 17, 45, 53, 123456789, time.UTC)
+	New().Time(ts).Write()
+	if !strings.Contains(buf.String(), `"time":"2023-09-26T17:45:53.123456789Z"`) {
+		t.Errorf("expected provided time in RFC3339Nano, got %q", buf.String())
+	}
+}